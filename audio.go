@@ -2,10 +2,10 @@ package main
 
 import (
 	"github.com/ilyail3/fileSync/gdrive"
+	"github.com/ilyail3/fileSync/progress"
+	"github.com/ilyail3/fileSync/retry"
 	"github.com/kardianos/osext"
-	"google.golang.org/api/drive/v3"
 	"io/ioutil"
-	"time"
 
 	"log"
 	"os"
@@ -29,7 +29,9 @@ func main() {
 		log.Fatalf("Failed to inialize google drive service: %v", err)
 	}
 
-	folderId, err := gdrive.GetOrCreateDirectory(srv, "youtube")
+	rmt := gdrive.NewDriveRemote(srv, retry.DefaultPolicy, nil)
+
+	folderId, err := rmt.GetOrCreateDirectory("", "youtube")
 
 	if err != nil {
 		log.Fatalf("Failed to create destination youtube folder")
@@ -51,13 +53,9 @@ func main() {
 
 		log.Printf("uploading %s", file.Name())
 
-		f := drive.File{
-			Name:         file.Name(),
-			Properties:   map[string]string{},
-			ModifiedTime: file.ModTime().Format(time.RFC3339),
-			Parents:      []string{folderId}}
-
-		_, err = srv.Files.Create(&f).Media(fh).Do()
+		content := progress.NewReader(fh, "uploading "+file.Name(), file.Size())
+		_, err = rmt.Upload(folderId, file.Name(), file.ModTime(), map[string]string{}, content)
+		content.Done()
 
 		if err != nil {
 			log.Fatalf("failed to upload file: %v", err)
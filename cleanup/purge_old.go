@@ -1,99 +1,36 @@
 package cleanup
 
 import (
-	"fmt"
-	"github.com/emirpasic/gods/sets"
-	"google.golang.org/api/drive/v3"
-	"log"
+	"github.com/ilyail3/fileSync/remote"
 	"time"
 )
 
-type FilesQuery func(srv *drive.Service, nextToken string) *drive.FilesListCall
-
-func purgeOldGpgSignatures(srv *drive.Service, gpgSignatures sets.Set, gpgQueryFunction FilesQuery) error {
-
-	var nextToken = ""
-
-	for {
-		r, err := gpgQueryFunction(srv, nextToken).Do()
-
-		if err != nil {
-			return fmt.Errorf("failed to query gpg files: %v", err)
-		}
-
-		for _, i := range r.Files {
-			if !gpgSignatures.Contains(i.Id) {
-				log.Printf("purging gpg signate %s from %s", i.Id, i.ModifiedTime)
-
-				err = srv.Files.Delete(i.Id).Do()
-				//log.Printf("delete signature:%s", i.Id)
-
-				if err != nil {
-					return fmt.Errorf("failed to delete file %s: %v", i.Id, err)
-				}
-			}
-		}
+// SelectPurge returns the versions the retention policy doesn't keep, and the signatures no kept version uses.
+// Signatures uploaded after the listing aren't in signatures, so they are never selected, and pendingSignatures
+// are kept for the unfinished uploads that will refer to them once resumed
+func SelectPurge(versions []*remote.Version, signatures []*remote.Version, pendingSignatures []string, policy RetentionPolicy, now time.Time) ([]*remote.Version, []*remote.Version) {
+	keep := policy.Keep(versions, now)
+	usedSignatures := make(map[string]bool)
+	purgeVersions := make([]*remote.Version, 0)
+	purgeSignatures := make([]*remote.Version, 0)
+
+	for _, id := range pendingSignatures {
+		usedSignatures[id] = true
+	}
 
-		if r.NextPageToken == "" {
-			return nil
-		} else {
-			nextToken = r.NextPageToken
+	for _, i := range versions {
+		if !keep[i.Id] {
+			purgeVersions = append(purgeVersions, i)
+		} else if gpg, exists := i.Properties["gpg"]; exists {
+			usedSignatures[gpg] = true
 		}
 	}
-}
 
-func PurgeOldFiles(srv *drive.Service, r *drive.FileList, maxMTime time.Time, queryFunction FilesQuery, gpgQueryFunction FilesQuery, gpgSignatures sets.Set) error {
-	if len(r.Files) > 0 {
-		for {
-			for _, i := range r.Files {
-				mTime, err := time.Parse(time.RFC3339, i.ModifiedTime)
-
-				if err != nil {
-					return fmt.Errorf("failed to parse modified time from google '%s': %v", i.ModifiedTime, err)
-				}
-
-				if mTime.Before(maxMTime) {
-					delta := time.Since(mTime)
-					hours := int(delta.Hours())
-					log.Printf("file %s is %d hours old", i.Id, hours)
-
-					if hours > 24*10 {
-						err := srv.Files.Delete(i.Id).Do()
-
-						if err != nil {
-							return fmt.Errorf("failed to cleanup old file: %v", err)
-						}
-					} else {
-						gpg, exists := i.Properties["gpg"]
-
-						if exists {
-							gpgSignatures.Add(gpg)
-						}
-					}
-				} else {
-					gpg, exists := i.Properties["gpg"]
-
-					if exists {
-						gpgSignatures.Add(gpg)
-					}
-				}
-			}
-
-			if r.NextPageToken == "" {
-				// log.Printf("gpg signatures: %d", gpgSignatures.Size())
-				return purgeOldGpgSignatures(srv, gpgSignatures, gpgQueryFunction)
-			} else {
-				nextR, err := queryFunction(srv, r.NextPageToken).Do()
-
-				if err != nil {
-					return fmt.Errorf("failed to get next page: %v", err)
-				}
-
-				r = nextR
-			}
+	for _, i := range signatures {
+		if !usedSignatures[i.Id] {
+			purgeSignatures = append(purgeSignatures, i)
 		}
-	} else {
-		// log.Printf("gpg signature: %d", gpgSignatures.Size())
-		return purgeOldGpgSignatures(srv, gpgSignatures, gpgQueryFunction)
 	}
+
+	return purgeVersions, purgeSignatures
 }
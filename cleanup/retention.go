@@ -0,0 +1,213 @@
+package cleanup
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides which older versions are kept, a version kept by any of the rules stays and
+// the newest version is always kept. Hourly, Daily, Weekly and Monthly keep the newest version of each
+// of that many most recent periods that have versions
+type RetentionPolicy struct {
+	KeepLast   int
+	KeepWithin time.Duration
+	Hourly     int
+	Daily      int
+	Weekly     int
+	Monthly    int
+}
+
+// DefaultRetentionPolicy keeps ten days of history
+var DefaultRetentionPolicy = RetentionPolicy{KeepWithin: 10 * 24 * time.Hour}
+
+const retentionConfigKey = "retention"
+
+func formatDuration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+
+	return d.String()
+}
+
+// parseDuration accepts time.ParseDuration values and whole days or weeks like 10d or 2w
+func parseDuration(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration '%s'", value)
+			}
+
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(value)
+}
+
+// String formats the policy the way ParseRetentionPolicy reads it, like last=5,within=10d,daily=7
+func (p RetentionPolicy) String() string {
+	parts := make([]string, 0)
+
+	for _, rule := range []struct {
+		name  string
+		count int
+	}{{"last", p.KeepLast}, {"hourly", p.Hourly}, {"daily", p.Daily}, {"weekly", p.Weekly}, {"monthly", p.Monthly}} {
+		if rule.count > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", rule.name, rule.count))
+		}
+	}
+
+	if p.KeepWithin > 0 {
+		parts = append(parts, "within="+formatDuration(p.KeepWithin))
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseRetentionPolicy reads comma separated rules, "none" keeps only the newest version
+func ParseRetentionPolicy(value string) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+
+	if value == "none" {
+		return policy, nil
+	}
+
+	for _, rule := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+
+		if len(parts) != 2 {
+			return RetentionPolicy{}, fmt.Errorf("retention rule '%s' isn't name=value", rule)
+		}
+
+		if parts[0] == "within" {
+			d, err := parseDuration(parts[1])
+
+			if err != nil || d < 0 {
+				return RetentionPolicy{}, fmt.Errorf("invalid retention duration '%s'", parts[1])
+			}
+
+			policy.KeepWithin = d
+			continue
+		}
+
+		count, err := strconv.Atoi(parts[1])
+
+		if err != nil || count < 0 {
+			return RetentionPolicy{}, fmt.Errorf("invalid retention count '%s'", parts[1])
+		}
+
+		switch parts[0] {
+		case "last":
+			policy.KeepLast = count
+		case "hourly":
+			policy.Hourly = count
+		case "daily":
+			policy.Daily = count
+		case "weekly":
+			policy.Weekly = count
+		case "monthly":
+			policy.Monthly = count
+		default:
+			return RetentionPolicy{}, fmt.Errorf("unknown retention rule '%s'", parts[0])
+		}
+	}
+
+	return policy, nil
+}
+
+// ReadRetentionPolicy returns the policy saved for the file, then the global one, then the default
+func ReadRetentionPolicy(db metadata.ConfigStore, address string) (RetentionPolicy, error) {
+	for _, key := range []string{retentionConfigKey + ":" + address, retentionConfigKey} {
+		exists, value, err := db.ReadStringConfig(key)
+
+		if err != nil {
+			return RetentionPolicy{}, fmt.Errorf("failed to read %s: %v", key, err)
+		}
+
+		if exists {
+			return ParseRetentionPolicy(value)
+		}
+	}
+
+	return DefaultRetentionPolicy, nil
+}
+
+// WriteRetentionPolicy saves the policy for a single file, or globally when address is empty
+func WriteRetentionPolicy(db metadata.ConfigStore, address string, policy RetentionPolicy) error {
+	key := retentionConfigKey
+
+	if address != "" {
+		key += ":" + address
+	}
+
+	return db.WriteStringConfig(key, policy.String())
+}
+
+func thinningKey(period string, t time.Time) string {
+	t = t.UTC()
+
+	switch period {
+	case "hourly":
+		return t.Format("2006-01-02T15")
+	case "daily":
+		return t.Format("2006-01-02")
+	case "weekly":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// Keep returns the ids of the versions the policy retains at the given time
+func (p RetentionPolicy) Keep(versions []*remote.Version, now time.Time) map[string]bool {
+	sorted := make([]*remote.Version, len(versions))
+	copy(sorted, versions)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[j].ModifiedTime.Before(sorted[i].ModifiedTime)
+	})
+
+	keep := make(map[string]bool)
+
+	for i, version := range sorted {
+		if i == 0 || i < p.KeepLast || now.Sub(version.ModifiedTime) < p.KeepWithin {
+			keep[version.Id] = true
+		}
+	}
+
+	for _, rule := range []struct {
+		period string
+		count  int
+	}{{"hourly", p.Hourly}, {"daily", p.Daily}, {"weekly", p.Weekly}, {"monthly", p.Monthly}} {
+		seen := make(map[string]bool)
+
+		// The newest version of a period represents it
+		for _, version := range sorted {
+			if len(seen) >= rule.count {
+				break
+			}
+
+			key := thinningKey(rule.period, version.ModifiedTime)
+
+			if !seen[key] {
+				seen[key] = true
+				keep[version.Id] = true
+			}
+		}
+	}
+
+	return keep
+}
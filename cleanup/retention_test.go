@@ -0,0 +1,252 @@
+package cleanup
+
+import (
+	"github.com/ilyail3/fileSync/remote"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected RetentionPolicy
+	}{
+		{"none", RetentionPolicy{}},
+		{"last=5", RetentionPolicy{KeepLast: 5}},
+		{"within=10d", RetentionPolicy{KeepWithin: 10 * 24 * time.Hour}},
+		{"within=2w", RetentionPolicy{KeepWithin: 14 * 24 * time.Hour}},
+		{"within=36h", RetentionPolicy{KeepWithin: 36 * time.Hour}},
+		{"last=5, within=10d,hourly=24,daily=7,weekly=4,monthly=12", RetentionPolicy{
+			KeepLast:   5,
+			KeepWithin: 10 * 24 * time.Hour,
+			Hourly:     24,
+			Daily:      7,
+			Weekly:     4,
+			Monthly:    12}},
+		{"last=0", RetentionPolicy{}},
+	}
+
+	for _, c := range cases {
+		policy, err := ParseRetentionPolicy(c.value)
+
+		if err != nil {
+			t.Errorf("%s: %v", c.value, err)
+			continue
+		}
+
+		if policy != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.value, c.expected, policy)
+		}
+	}
+}
+
+func TestParseMalformedRetentionPolicy(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"last",
+		"last=",
+		"last=five",
+		"last=-1",
+		"daily=1.5",
+		"within=soon",
+		"within=-1d",
+		"within=-2h",
+		"within=xd",
+		"yearly=1",
+		"last=5,",
+		"last=5;daily=7",
+	} {
+		policy, err := ParseRetentionPolicy(value)
+
+		if err == nil {
+			t.Errorf("expected '%s' to be rejected, got %+v", value, policy)
+		}
+	}
+}
+
+func TestRetentionPolicyStringRoundTrip(t *testing.T) {
+	for _, policy := range []RetentionPolicy{
+		{},
+		DefaultRetentionPolicy,
+		{KeepLast: 3},
+		{KeepWithin: 90 * time.Minute},
+		{KeepWithin: 36 * time.Hour, Monthly: 6},
+		{KeepLast: 5, KeepWithin: 2 * 24 * time.Hour, Hourly: 24, Daily: 7, Weekly: 4, Monthly: 12},
+	} {
+		value := policy.String()
+		parsed, err := ParseRetentionPolicy(value)
+
+		if err != nil {
+			t.Errorf("%+v formatted as '%s' didn't parse: %v", policy, value, err)
+			continue
+		}
+
+		if parsed != policy {
+			t.Errorf("%+v formatted as '%s' parsed back as %+v", policy, value, parsed)
+		}
+	}
+
+	if value := (RetentionPolicy{}).String(); value != "none" {
+		t.Errorf("expected an empty policy to format as none, got '%s'", value)
+	}
+
+	if value := DefaultRetentionPolicy.String(); value != "within=10d" {
+		t.Errorf("expected the default policy to format as within=10d, got '%s'", value)
+	}
+}
+
+func TestThinningKey(t *testing.T) {
+	eastern := time.FixedZone("UTC+2", 2*60*60)
+
+	cases := []struct {
+		period   string
+		time     time.Time
+		expected string
+	}{
+		{"hourly", time.Date(2021, 3, 7, 23, 59, 59, 0, time.UTC), "2021-03-07T23"},
+		{"daily", time.Date(2021, 3, 7, 23, 59, 59, 0, time.UTC), "2021-03-07"},
+		// Periods are UTC, a local time past midnight still belongs to the previous UTC day and month
+		{"daily", time.Date(2021, 2, 1, 0, 30, 0, 0, eastern), "2021-01-31"},
+		{"monthly", time.Date(2021, 2, 1, 0, 30, 0, 0, eastern), "2021-01"},
+		// ISO weeks start on Monday, the first days of a year can belong to the last week of the one before
+		{"weekly", time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC), "2020-W53"},
+		{"weekly", time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC), "2021-W01"},
+		{"weekly", time.Date(2019, 12, 30, 12, 0, 0, 0, time.UTC), "2020-W01"},
+		{"monthly", time.Date(2020, 12, 31, 23, 0, 0, 0, time.UTC), "2020-12"},
+		{"monthly", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "2021-01"},
+	}
+
+	for _, c := range cases {
+		key := thinningKey(c.period, c.time)
+
+		if key != c.expected {
+			t.Errorf("%s key of %v: expected %s, got %s", c.period, c.time, c.expected, key)
+		}
+	}
+}
+
+func TestKeep(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	at := func(id string, modifiedTime time.Time) *remote.Version {
+		return &remote.Version{Id: id, ModifiedTime: modifiedTime}
+	}
+
+	ago := func(id string, age time.Duration) *remote.Version {
+		return at(id, now.Add(-age))
+	}
+
+	hour := time.Hour
+	day := 24 * time.Hour
+
+	cases := []struct {
+		name     string
+		policy   RetentionPolicy
+		versions []*remote.Version
+		expected []string
+	}{
+		{
+			name:     "no versions",
+			policy:   DefaultRetentionPolicy,
+			versions: []*remote.Version{},
+			expected: []string{}},
+		{
+			name:     "newest is always kept",
+			policy:   RetentionPolicy{},
+			versions: []*remote.Version{ago("old", 400*day), ago("older", 500*day)},
+			expected: []string{"old"}},
+		{
+			name:     "last",
+			policy:   RetentionPolicy{KeepLast: 2},
+			versions: []*remote.Version{ago("c", 3*hour), ago("a", hour), ago("d", 4*hour), ago("b", 2*hour)},
+			expected: []string{"a", "b"}},
+		{
+			name:     "last beyond the versions",
+			policy:   RetentionPolicy{KeepLast: 10},
+			versions: []*remote.Version{ago("a", hour), ago("b", 2*hour)},
+			expected: []string{"a", "b"}},
+		{
+			name:     "within is exclusive",
+			policy:   RetentionPolicy{KeepWithin: 2 * day},
+			versions: []*remote.Version{ago("a", hour), ago("b", 2*day-time.Second), ago("c", 2*day), ago("d", 3*day)},
+			expected: []string{"a", "b"}},
+		{
+			name:   "hourly keeps the newest of each hour",
+			policy: RetentionPolicy{Hourly: 2},
+			versions: []*remote.Version{
+				at("a", time.Date(2021, 3, 15, 11, 50, 0, 0, time.UTC)),
+				at("b", time.Date(2021, 3, 15, 11, 10, 0, 0, time.UTC)),
+				at("c", time.Date(2021, 3, 15, 10, 59, 0, 0, time.UTC)),
+				at("d", time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)),
+				at("e", time.Date(2021, 3, 15, 9, 0, 0, 0, time.UTC))},
+			expected: []string{"a", "c"}},
+		{
+			name:   "daily counts only days with versions",
+			policy: RetentionPolicy{Daily: 3},
+			versions: []*remote.Version{
+				at("a", time.Date(2021, 3, 15, 8, 0, 0, 0, time.UTC)),
+				at("b", time.Date(2021, 3, 15, 7, 0, 0, 0, time.UTC)),
+				at("c", time.Date(2021, 3, 10, 23, 59, 0, 0, time.UTC)),
+				at("d", time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)),
+				at("e", time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)),
+				at("f", time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))},
+			expected: []string{"a", "c", "e"}},
+		{
+			name:   "weekly across the iso year",
+			policy: RetentionPolicy{Weekly: 2},
+			versions: []*remote.Version{
+				at("monday", time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)),
+				at("sunday", time.Date(2021, 1, 3, 9, 0, 0, 0, time.UTC)),
+				at("new-year", time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC)),
+				at("week-52", time.Date(2020, 12, 27, 9, 0, 0, 0, time.UTC))},
+			expected: []string{"monday", "sunday"}},
+		{
+			name:   "monthly across the year",
+			policy: RetentionPolicy{Monthly: 2},
+			versions: []*remote.Version{
+				at("january", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+				at("december-last", time.Date(2020, 12, 31, 23, 59, 59, 0, time.UTC)),
+				at("december-first", time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)),
+				at("november", time.Date(2020, 11, 30, 0, 0, 0, 0, time.UTC))},
+			expected: []string{"december-last", "january"}},
+		{
+			name:   "last combined with periods",
+			policy: RetentionPolicy{KeepLast: 2, Monthly: 3},
+			versions: []*remote.Version{
+				at("a", time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)),
+				at("b", time.Date(2021, 3, 14, 11, 0, 0, 0, time.UTC)),
+				at("c", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)),
+				at("d", time.Date(2021, 2, 20, 11, 0, 0, 0, time.UTC)),
+				at("e", time.Date(2021, 2, 10, 11, 0, 0, 0, time.UTC)),
+				at("f", time.Date(2020, 11, 10, 11, 0, 0, 0, time.UTC)),
+				at("g", time.Date(2020, 10, 10, 11, 0, 0, 0, time.UTC))},
+			expected: []string{"a", "b", "d", "f"}},
+		{
+			name:   "within combined with periods",
+			policy: RetentionPolicy{KeepWithin: day, Daily: 2, Weekly: 3},
+			versions: []*remote.Version{
+				ago("a", hour),
+				ago("b", 2*hour),
+				ago("c", 2*day),
+				ago("d", 2*day+hour),
+				ago("e", 10*day)},
+			expected: []string{"a", "b", "c", "e"}},
+	}
+
+	for _, c := range cases {
+		keep := c.policy.Keep(c.versions, now)
+		kept := make([]string, 0, len(keep))
+
+		for id := range keep {
+			kept = append(kept, id)
+		}
+
+		sort.Strings(kept)
+
+		if !reflect.DeepEqual(kept, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, kept)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gdrive
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"google.golang.org/api/drive/v3"
+)
+
+func (d *DriveRemote) StartChangeToken() (string, error) {
+	var r *drive.StartPageToken
+
+	err := d.retry.Do(func() (err error) {
+		r, err = d.srv.Changes.GetStartPageToken().Do()
+		return err
+	}, retryable)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get changes start token: %v", err)
+	}
+
+	return r.StartPageToken, nil
+}
+
+func (d *DriveRemote) ListChanges(token string) ([]*remote.Change, string, error) {
+	changes := make([]*remote.Change, 0)
+
+	for {
+		var r *drive.ChangeList
+
+		err := d.retry.Do(func() (err error) {
+			r, err = d.srv.Changes.List(token).PageSize(1000).
+				Fields("nextPageToken, newStartPageToken, changes(removed, file(name, parents, mimeType))").
+				Do()
+			return err
+		}, retryable)
+
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to retrieve changes: %v", err)
+		}
+
+		for _, i := range r.Changes {
+			// Deleted versions leave nothing new to download
+			if i.Removed || i.File == nil || i.File.MimeType == FolderMimeType {
+				continue
+			}
+
+			changes = append(changes, &remote.Change{Name: i.File.Name, ParentIds: i.File.Parents})
+		}
+
+		if r.NewStartPageToken != "" {
+			return changes, r.NewStartPageToken, nil
+		}
+
+		token = r.NextPageToken
+	}
+}
@@ -0,0 +1,77 @@
+package fakedrive
+
+import (
+	"google.golang.org/api/drive/v3"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const changesPath = "/drive/v3/changes"
+const startPageTokenPath = changesPath + "/startPageToken"
+
+// recordChange appends to the change log, page tokens are offsets into it. The mutex must be held
+func (s *Server) recordChange(id string, meta *drive.File) {
+	change := &drive.Change{
+		ChangeType: "file",
+		FileId:     id,
+		Removed:    meta == nil,
+		Time:       time.Now().UTC().Format(timeFormat)}
+
+	if meta != nil {
+		file := *meta
+		change.File = &file
+	}
+
+	s.changes = append(s.changes, change)
+}
+
+func (s *Server) startPageToken(w http.ResponseWriter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, &drive.StartPageToken{StartPageToken: strconv.Itoa(len(s.changes))})
+}
+
+func (s *Server) listChanges(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("pageToken")
+	offset, err := strconv.Atoi(token)
+
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid", "invalid pageToken "+token)
+		return
+	}
+
+	pageSize := 100
+
+	if value := r.URL.Query().Get("pageSize"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+
+		if err != nil || pageSize <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid", "invalid pageSize "+value)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if offset > len(s.changes) {
+		writeError(w, http.StatusBadRequest, "invalid", "invalid pageToken "+token)
+		return
+	}
+
+	end := offset + pageSize
+	result := drive.ChangeList{Changes: make([]*drive.Change, 0)}
+
+	if end < len(s.changes) {
+		result.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(s.changes)
+		result.NewStartPageToken = strconv.Itoa(end)
+	}
+
+	result.Changes = append(result.Changes, s.changes[offset:end]...)
+
+	writeJSON(w, http.StatusOK, &result)
+}
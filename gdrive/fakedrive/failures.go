@@ -0,0 +1,51 @@
+package fakedrive
+
+import (
+	"net/http"
+)
+
+// Failure is an error answered instead of serving a request, RetryAfter is sent as the Retry-After header when set
+type Failure struct {
+	Status     int
+	Reason     string
+	RetryAfter string
+}
+
+// FailNext has the next requests answered with the failures, one request each in order
+func (s *Server) FailNext(failures ...Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures = append(s.failures, failures...)
+}
+
+// Requests returns how many requests were received, failed ones included
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+// injectFailure answers the request with the next queued failure, returning false when none is left
+func (s *Server) injectFailure(w http.ResponseWriter) bool {
+	s.mutex.Lock()
+	s.requests++
+
+	if len(s.failures) == 0 {
+		s.mutex.Unlock()
+		return false
+	}
+
+	failure := s.failures[0]
+	s.failures = s.failures[1:]
+	s.mutex.Unlock()
+
+	if failure.RetryAfter != "" {
+		w.Header().Set("Retry-After", failure.RetryAfter)
+	}
+
+	writeError(w, failure.Status, failure.Reason, http.StatusText(failure.Status))
+
+	return true
+}
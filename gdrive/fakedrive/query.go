@@ -0,0 +1,162 @@
+package fakedrive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"strings"
+)
+
+// condition is a single clause of a files.list q parameter
+type condition func(file *drive.File) bool
+
+// tokenize splits a drive query into quoted strings and bare words, quoted strings keep their quotes
+func tokenize(query string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		switch {
+		case runes[i] == ' ':
+			i++
+		case runes[i] == '\'':
+			var value strings.Builder
+			value.WriteRune('\'')
+			i++
+
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+
+				value.WriteRune(runes[i])
+			}
+
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string in query: %s", query)
+			}
+
+			i++
+			tokens = append(tokens, value.String())
+		case runes[i] == '=':
+			tokens = append(tokens, "=")
+			i++
+		case runes[i] == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, "!=")
+			i += 2
+		default:
+			start := i
+
+			for ; i < len(runes) && runes[i] != ' ' && runes[i] != '=' && runes[i] != '!' && runes[i] != '\''; i++ {
+			}
+
+			tokens = append(tokens, string(runes[start:i]))
+		}
+	}
+
+	return tokens, nil
+}
+
+func isString(token string) bool {
+	return strings.HasPrefix(token, "'")
+}
+
+func fieldValue(file *drive.File, field string) (string, error) {
+	switch field {
+	case "name":
+		return file.Name, nil
+	case "mimeType":
+		return file.MimeType, nil
+	case "trashed":
+		return fmt.Sprintf("%t", file.Trashed), nil
+	default:
+		return "", fmt.Errorf("unsupported query field '%s'", field)
+	}
+}
+
+func inParents(file *drive.File, parentId string) bool {
+	for _, parent := range file.Parents {
+		if parent == parentId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseClause(tokens []string) (condition, error) {
+	if len(tokens) != 3 {
+		return nil, fmt.Errorf("unsupported query clause: %s", strings.Join(tokens, " "))
+	}
+
+	left, op, right := tokens[0], tokens[1], tokens[2]
+
+	switch op {
+	case "in":
+		// Both the documented '<id>' in parents and the parents in '<id>' forms are accepted
+		if isString(left) && right == "parents" {
+			return func(file *drive.File) bool { return inParents(file, left[1:]) }, nil
+		} else if left == "parents" && isString(right) {
+			return func(file *drive.File) bool { return inParents(file, right[1:]) }, nil
+		}
+
+		return nil, fmt.Errorf("unsupported in clause: %s in %s", left, right)
+	case "=", "!=":
+		value := strings.TrimPrefix(right, "'")
+		_, err := fieldValue(&drive.File{}, left)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(file *drive.File) bool {
+			actual, _ := fieldValue(file, left)
+			return (actual == value) == (op == "=")
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query operator '%s'", op)
+	}
+}
+
+// parseQuery supports the subset of the drive query language made of and-ed name, mimeType,
+// trashed and parents clauses
+func parseQuery(query string) (condition, error) {
+	tokens, err := tokenize(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make([]condition, 0)
+	start := 0
+
+	for i := 0; i <= len(tokens); i++ {
+		if i == len(tokens) || tokens[i] == "and" {
+			if i == start {
+				if len(tokens) == 0 {
+					break
+				}
+
+				return nil, fmt.Errorf("empty clause in query: %s", query)
+			}
+
+			c, err := parseClause(tokens[start:i])
+
+			if err != nil {
+				return nil, err
+			}
+
+			conditions = append(conditions, c)
+			start = i + 1
+		}
+	}
+
+	return func(file *drive.File) bool {
+		for _, c := range conditions {
+			if !c(file) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
@@ -0,0 +1,133 @@
+package fakedrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type uploadSession struct {
+	meta    drive.File
+	size    int64
+	content []byte
+	// file is set once every byte was received
+	file *drive.File
+}
+
+// UploadSessions returns how many resumable uploads were started
+func (s *Server) UploadSessions() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.sessions)
+}
+
+// ExpireUploadSessions forgets every resumable upload, like drive does after a week
+func (s *Server) ExpireUploadSessions() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id := range s.sessions {
+		s.sessions[id] = nil
+	}
+}
+
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request) {
+	var meta drive.File
+
+	err := json.NewDecoder(r.Body).Decode(&meta)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("failed to decode file metadata: %v", err))
+		return
+	}
+
+	size, err := strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+
+	if err != nil || size < 0 {
+		writeError(w, http.StatusBadRequest, "invalid", "invalid X-Upload-Content-Length")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, parent := range meta.Parents {
+		if _, exists := s.files[parent]; !exists {
+			writeError(w, http.StatusNotFound, "notFound", "File not found: "+parent)
+			return
+		}
+	}
+
+	id := strconv.Itoa(len(s.sessions) + 1)
+	s.sessions[id] = &uploadSession{meta: meta, size: size, content: make([]byte, 0)}
+
+	w.Header().Set("Location", s.URL+uploadFilesPath+"?uploadType=resumable&upload_id="+id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// sessionProgress answers with the received range, or the file once complete. The mutex must be held
+func sessionProgress(w http.ResponseWriter, session *uploadSession) {
+	if session.file != nil {
+		writeJSON(w, http.StatusOK, session.file)
+		return
+	}
+
+	if len(session.content) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(session.content)-1))
+	}
+
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// putChunk stores a chunk given by Content-Range as bytes first-last/size, or reports progress for bytes */size
+func (s *Server) putChunk(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("failed to read chunk: %v", err))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session := s.sessions[r.URL.Query().Get("upload_id")]
+
+	if session == nil {
+		writeError(w, http.StatusNotFound, "notFound", "upload session not found")
+		return
+	}
+
+	contentRange := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+
+	if strings.HasPrefix(contentRange, "*/") {
+		sessionProgress(w, session)
+		return
+	}
+
+	var first, last, size int64
+
+	_, err = fmt.Sscanf(contentRange, "%d-%d/%d", &first, &last, &size)
+
+	if err != nil || size != session.size || last-first+1 != int64(len(body)) || first > int64(len(session.content)) {
+		writeError(w, http.StatusBadRequest, "invalid", "invalid Content-Range "+r.Header.Get("Content-Range"))
+		return
+	}
+
+	if session.file == nil {
+		// Bytes already received are skipped, a client may send them again after a failure
+		session.content = append(session.content[:first], body...)
+
+		if int64(len(session.content)) == session.size {
+			meta := session.meta
+			session.file = s.store(&meta, session.content)
+		}
+	}
+
+	sessionProgress(w, session)
+}
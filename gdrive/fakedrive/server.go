@@ -0,0 +1,375 @@
+package fakedrive
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const filesPath = "/drive/v3/files"
+const uploadFilesPath = "/upload/drive/v3/files"
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+type storedFile struct {
+	meta    drive.File
+	content []byte
+}
+
+// Server is an in-process stand in for the drive v3 files endpoints, it keeps files in memory
+// and supports list with q filtering and paging, metadata and multipart create, get, media download, delete
+// listing changes and resumable uploads. Failures can be injected to test retries
+type Server struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	files  map[string]*storedFile
+	order  []string
+	nextId int
+	// changes is the log served by the changes endpoints
+	changes []*drive.Change
+
+	failures []Failure
+	requests int
+
+	sessions map[string]*uploadSession
+}
+
+func NewServer() *Server {
+	s := &Server{files: make(map[string]*storedFile), sessions: make(map[string]*uploadSession)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Service returns a drive client talking to the fake server
+func (s *Server) Service() (*drive.Service, error) {
+	srv, err := drive.New(s.Client())
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to create drive client: %v", err)
+	}
+
+	srv.BasePath = s.URL + "/drive/v3/"
+
+	return srv, nil
+}
+
+// Files returns a copy of the metadata of every stored file in creation order
+func (s *Server) Files() []*drive.File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files := make([]*drive.File, 0, len(s.order))
+
+	for _, id := range s.order {
+		meta := s.files[id].meta
+		files = append(files, &meta)
+	}
+
+	return files
+}
+
+// Content returns the media of a stored file
+func (s *Server) Content(id string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, exists := s.files[id]
+
+	if !exists {
+		return nil, false
+	}
+
+	return f.content, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+
+	if err != nil {
+		log.Printf("failed to write fake drive response: %v", err)
+	}
+}
+
+// writeError answers in the drive error format so googleapi.Error is populated
+func writeError(w http.ResponseWriter, status int, reason string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"errors": []map[string]string{
+				{"domain": "global", "reason": reason, "message": message}}}})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if s.injectFailure(w) {
+		return
+	}
+
+	switch {
+	case r.URL.Path == uploadFilesPath && r.URL.Query().Get("uploadType") == "resumable":
+		switch r.Method {
+		case http.MethodPost:
+			s.startSession(w, r)
+		case http.MethodPut:
+			s.putChunk(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "method not allowed")
+		}
+	case r.URL.Path == filesPath || r.URL.Path == uploadFilesPath:
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w, r)
+		case http.MethodPost:
+			s.create(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "method not allowed")
+		}
+	case strings.HasPrefix(r.URL.Path, filesPath+"/"):
+		id := strings.TrimPrefix(r.URL.Path, filesPath+"/")
+
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, r, id)
+		case http.MethodDelete:
+			s.delete(w, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "methodNotAllowed", "method not allowed")
+		}
+	case r.URL.Path == startPageTokenPath && r.Method == http.MethodGet:
+		s.startPageToken(w)
+	case r.URL.Path == changesPath && r.Method == http.MethodGet:
+		s.listChanges(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "notFound", "unknown path "+r.URL.Path)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	matches, err := parseQuery(r.URL.Query().Get("q"))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	pageSize := 100
+
+	if value := r.URL.Query().Get("pageSize"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+
+		if err != nil || pageSize <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid", "invalid pageSize "+value)
+			return
+		}
+	}
+
+	offset := 0
+
+	if token := r.URL.Query().Get("pageToken"); token != "" {
+		offset, err = strconv.Atoi(token)
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", "invalid pageToken "+token)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := drive.FileList{Files: make([]*drive.File, 0)}
+	index := 0
+
+	for _, id := range s.order {
+		meta := s.files[id].meta
+
+		if !matches(&meta) {
+			continue
+		}
+
+		if index >= offset {
+			if len(result.Files) == pageSize {
+				result.NextPageToken = strconv.Itoa(index)
+				break
+			}
+
+			result.Files = append(result.Files, &meta)
+		}
+
+		index++
+	}
+
+	writeJSON(w, http.StatusOK, &result)
+}
+
+// readUpload extracts the file metadata and media from a create request
+func readUpload(r *http.Request) (*drive.File, []byte, error) {
+	var meta drive.File
+
+	switch r.URL.Query().Get("uploadType") {
+	case "":
+		err := json.NewDecoder(r.Body).Decode(&meta)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode file metadata: %v", err)
+		}
+
+		return &meta, nil, nil
+	case "media":
+		content, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read media: %v", err)
+		}
+
+		return &meta, content, nil
+	case "multipart":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse content type: %v", err)
+		}
+
+		reader := multipart.NewReader(r.Body, params["boundary"])
+
+		part, err := reader.NextPart()
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read metadata part: %v", err)
+		}
+
+		err = json.NewDecoder(part).Decode(&meta)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode file metadata: %v", err)
+		}
+
+		part, err = reader.NextPart()
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read media part: %v", err)
+		}
+
+		content, err := ioutil.ReadAll(part)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read media: %v", err)
+		}
+
+		return &meta, content, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported uploadType %s", r.URL.Query().Get("uploadType"))
+	}
+}
+
+// store assigns an id and the server computed fields to a new file, the mutex must be held
+func (s *Server) store(meta *drive.File, content []byte) *drive.File {
+	s.nextId++
+	meta.Id = fmt.Sprintf("fake-%d", s.nextId)
+
+	if meta.ModifiedTime == "" {
+		meta.ModifiedTime = time.Now().UTC().Format(timeFormat)
+	}
+
+	if meta.MimeType == "" {
+		meta.MimeType = "application/octet-stream"
+	}
+
+	if content != nil {
+		sum := md5.Sum(content)
+		meta.Md5Checksum = hex.EncodeToString(sum[:])
+		meta.Size = int64(len(content))
+	}
+
+	s.files[meta.Id] = &storedFile{meta: *meta, content: content}
+	s.order = append(s.order, meta.Id)
+	s.recordChange(meta.Id, meta)
+
+	return meta
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	meta, content, err := readUpload(r)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, parent := range meta.Parents {
+		if _, exists := s.files[parent]; !exists {
+			writeError(w, http.StatusNotFound, "notFound", "File not found: "+parent)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, s.store(meta, content))
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	f, exists := s.files[id]
+	s.mutex.Unlock()
+
+	if !exists {
+		writeError(w, http.StatusNotFound, "notFound", "File not found: "+id)
+		return
+	}
+
+	if r.URL.Query().Get("alt") != "media" {
+		writeJSON(w, http.StatusOK, &f.meta)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.meta.MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, strings.NewReader(string(f.content)))
+
+	if err != nil {
+		log.Printf("failed to write fake drive media: %v", err)
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.files[id]; !exists {
+		writeError(w, http.StatusNotFound, "notFound", "File not found: "+id)
+		return
+	}
+
+	delete(s.files, id)
+	s.recordChange(id, nil)
+
+	for i, storedId := range s.order {
+		if storedId == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,20 +2,36 @@ package gdrive
 
 import (
 	"fmt"
-	"github.com/ilyail3/fileSync/cleanup"
 	"google.golang.org/api/drive/v3"
-	"net/url"
 )
 
-func ListFilesQuery(parentId string, fileName string) cleanup.FilesQuery {
+type FilesQuery func(srv *drive.Service, nextToken string) *drive.FilesListCall
+
+func ListFilesQuery(parentId string, fileName string) FilesQuery {
 	return func(srv *drive.Service, nextToken string) *drive.FilesListCall {
 		query := fmt.Sprintf(
 			"name='%s' and parents in '%s'",
-			url.QueryEscape(fileName),
-			url.QueryEscape(parentId))
+			escapeQueryValue(fileName),
+			escapeQueryValue(parentId))
 
 		r := srv.Files.List().PageSize(10).
-			Fields("nextPageToken, files(id, name, modifiedTime, properties)").
+			Fields("nextPageToken, files(id, name, modifiedTime, properties, md5Checksum, size)").
+			Q(query)
+
+		if nextToken != "" {
+			r = r.PageToken(nextToken)
+		}
+
+		return r
+	}
+}
+
+func ListFolderQuery(parentId string) FilesQuery {
+	return func(srv *drive.Service, nextToken string) *drive.FilesListCall {
+		query := fmt.Sprintf("parents in '%s'", escapeQueryValue(parentId))
+
+		r := srv.Files.List().PageSize(1000).
+			Fields("nextPageToken, files(id, name, mimeType, modifiedTime, properties, md5Checksum, size)").
 			Q(query)
 
 		if nextToken != "" {
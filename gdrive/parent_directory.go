@@ -2,33 +2,69 @@ package gdrive
 
 import (
 	"fmt"
+	"github.com/ilyail3/fileSync/retry"
 	"google.golang.org/api/drive/v3"
-	"net/url"
 )
 
 const FolderMimeType = "application/vnd.google-apps.folder"
 
-func GetOrCreateDirectory(srv *drive.Service, directoryName string) (string, error) {
-	// Get parent directory
-	fList, err := srv.Files.List().Q(fmt.Sprintf(
+// FindDirectory looks the folder up without creating it, exists is false when it's missing
+func FindDirectory(srv *drive.Service, retryPolicy retry.Policy, parentId string, directoryName string) (string, bool, error) {
+	query := fmt.Sprintf(
 		"name='%s' and mimeType='%s'",
-		url.QueryEscape(directoryName),
-		FolderMimeType)).Fields("files(id, mimeType)").Do()
+		escapeQueryValue(directoryName),
+		FolderMimeType)
+
+	if parentId != "" {
+		query += fmt.Sprintf(" and parents in '%s'", escapeQueryValue(parentId))
+	}
+
+	var fList *drive.FileList
+
+	err := retryPolicy.Do(func() (err error) {
+		fList, err = srv.Files.List().Q(query).Fields("files(id, mimeType)").Do()
+		return err
+	}, retryable)
 
 	if err != nil {
-		return "", fmt.Errorf("failed to lookup sync directory: %v", err)
+		return "", false, fmt.Errorf("failed to lookup sync directory: %v", err)
 	}
 
 	if len(fList.Files) == 0 {
-		var folderFile *drive.File
-		folderFile, err = srv.Files.Create(&drive.File{Name: directoryName, MimeType: FolderMimeType}).Do()
+		return "", false, nil
+	}
 
-		if err != nil {
-			return "", fmt.Errorf("failed to create sync folder: %v", err)
-		}
+	return fList.Files[0].Id, true, nil
+}
+
+func GetOrCreateDirectory(srv *drive.Service, retryPolicy retry.Policy, parentId string, directoryName string) (string, error) {
+	// Get parent directory
+	id, exists, err := FindDirectory(srv, retryPolicy, parentId, directoryName)
 
-		return folderFile.Id, nil
-	} else {
-		return fList.Files[0].Id, nil
+	if err != nil {
+		return "", err
 	}
+
+	if exists {
+		return id, nil
+	}
+
+	folder := &drive.File{Name: directoryName, MimeType: FolderMimeType}
+
+	if parentId != "" {
+		folder.Parents = []string{parentId}
+	}
+
+	var folderFile *drive.File
+
+	err = retryPolicy.Do(func() (err error) {
+		folderFile, err = srv.Files.Create(folder).Do()
+		return err
+	}, retryable)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create sync folder: %v", err)
+	}
+
+	return folderFile.Id, nil
 }
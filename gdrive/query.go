@@ -0,0 +1,8 @@
+package gdrive
+
+import "strings"
+
+// escapeQueryValue escapes a value placed between single quotes of a files.list query
+func escapeQueryValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
@@ -0,0 +1,200 @@
+package gdrive
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/retry"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DriveRemote stores file versions as google drive files sharing the same name,
+// calls failing on rate limits or server errors are retried following the retry policy
+type DriveRemote struct {
+	srv   *drive.Service
+	retry retry.Policy
+	// uploads sends big files in resumable chunks, nil uploads every file in a single request
+	uploads *ResumableUploads
+}
+
+func NewDriveRemote(srv *drive.Service, retryPolicy retry.Policy, uploads *ResumableUploads) *DriveRemote {
+	return &DriveRemote{srv: srv, retry: retryPolicy, uploads: uploads}
+}
+
+func toVersion(file *drive.File) (*remote.Version, error) {
+	mTime, err := time.Parse(time.RFC3339, file.ModifiedTime)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modified time from google '%s': %v", file.ModifiedTime, err)
+	}
+
+	properties := file.Properties
+
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+
+	return &remote.Version{
+		Id:           file.Id,
+		Name:         file.Name,
+		ModifiedTime: mTime,
+		Properties:   properties,
+		Md5Checksum:  file.Md5Checksum,
+		Size:         file.Size}, nil
+}
+
+func (d *DriveRemote) GetOrCreateDirectory(parentId string, directoryName string) (string, error) {
+	return GetOrCreateDirectory(d.srv, d.retry, parentId, directoryName)
+}
+
+func (d *DriveRemote) FindDirectory(parentId string, directoryName string) (string, bool, error) {
+	return FindDirectory(d.srv, d.retry, parentId, directoryName)
+}
+
+// listFiles runs the query through every page, skipping folders
+func (d *DriveRemote) listFiles(queryFunction FilesQuery) ([]*remote.Version, error) {
+	versions := make([]*remote.Version, 0)
+
+	var nextToken = ""
+
+	for {
+		var r *drive.FileList
+
+		err := d.retry.Do(func() (err error) {
+			r, err = queryFunction(d.srv, nextToken).Do()
+			return err
+		}, retryable)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve files: %v", err)
+		}
+
+		for _, i := range r.Files {
+			if i.MimeType == FolderMimeType {
+				continue
+			}
+
+			version, err := toVersion(i)
+
+			if err != nil {
+				return nil, err
+			}
+
+			versions = append(versions, version)
+		}
+
+		if r.NextPageToken == "" {
+			return versions, nil
+		} else {
+			nextToken = r.NextPageToken
+		}
+	}
+}
+
+func (d *DriveRemote) ListVersions(parentId string, fileName string) ([]*remote.Version, error) {
+	return d.listFiles(ListFilesQuery(parentId, fileName))
+}
+
+func (d *DriveRemote) ListFolder(parentId string) ([]*remote.Version, error) {
+	return d.listFiles(ListFolderQuery(parentId))
+}
+
+func (d *DriveRemote) Upload(parentId string, fileName string, modTime time.Time, properties map[string]string, content io.Reader) (*remote.Version, error) {
+	f := drive.File{
+		Name:         fileName,
+		Properties:   properties,
+		ModifiedTime: modTime.Format(time.RFC3339),
+		Parents:      []string{parentId}}
+
+	if readSeeker, ok := content.(io.ReadSeeker); ok && d.uploads != nil {
+		start, size, hash, err := remote.ContentInfo(readSeeker)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if size > d.uploads.chunkSize {
+			resultFile, err := d.resumableUpload(&f, readSeeker, start, size, hash)
+
+			if err != nil {
+				return nil, fmt.Errorf("upload operation failed: %v", err)
+			}
+
+			return toVersion(resultFile)
+		}
+	}
+
+	// A retry sends the content again from the start, only possible when it can be rewound
+	seeker, canSeek := content.(io.Seeker)
+	policy := d.retry
+
+	var start int64
+
+	if canSeek {
+		var err error
+		start, err = seeker.Seek(0, io.SeekCurrent)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content position: %v", err)
+		}
+	} else {
+		policy.MaxRetries = 0
+	}
+
+	var attempt = 0
+	var resultFile *drive.File
+
+	err := policy.Do(func() (err error) {
+		if attempt > 0 {
+			_, err = seeker.Seek(start, io.SeekStart)
+
+			if err != nil {
+				return fmt.Errorf("failed to rewind content: %v", err)
+			}
+		}
+
+		attempt++
+
+		resultFile, err = d.srv.Files.Create(&f).
+			Fields("id, name, modifiedTime, properties, md5Checksum, size").
+			Media(content).
+			Do()
+
+		return err
+	}, retryable)
+
+	if err != nil {
+		return nil, fmt.Errorf("upload operation failed: %v", err)
+	}
+
+	return toVersion(resultFile)
+}
+
+func (d *DriveRemote) Download(id string) (io.ReadCloser, error) {
+	var f *http.Response
+
+	err := d.retry.Do(func() (err error) {
+		f, err = d.srv.Files.Get(id).Download()
+		return err
+	}, retryable)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %v", id, err)
+	}
+
+	return f.Body, nil
+}
+
+func (d *DriveRemote) Delete(id string) error {
+	err := d.retry.Do(func() error {
+		return d.srv.Files.Delete(id).Do()
+	}, retryable)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete file %s: %v", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package gdrive
+
+import (
+	"github.com/ilyail3/fileSync/gdrive/fakedrive"
+	"github.com/ilyail3/fileSync/retry"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPolicy = retry.Policy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func newTestRemote(t *testing.T) (*fakedrive.Server, *DriveRemote) {
+	server := fakedrive.NewServer()
+	t.Cleanup(server.Close)
+
+	srv, err := server.Service()
+
+	if err != nil {
+		t.Fatalf("failed to create drive client: %v", err)
+	}
+
+	return server, NewDriveRemote(srv, testPolicy, nil)
+}
+
+func TestRetriedFailures(t *testing.T) {
+	cases := []struct {
+		name    string
+		failure fakedrive.Failure
+	}{
+		{"too many requests", fakedrive.Failure{Status: http.StatusTooManyRequests}},
+		{"rate limit exceeded", fakedrive.Failure{Status: http.StatusForbidden, Reason: "rateLimitExceeded"}},
+		{"user rate limit exceeded", fakedrive.Failure{Status: http.StatusForbidden, Reason: "userRateLimitExceeded"}},
+		{"service unavailable", fakedrive.Failure{Status: http.StatusServiceUnavailable}},
+	}
+
+	for _, c := range cases {
+		server, rmt := newTestRemote(t)
+		server.FailNext(c.failure)
+
+		_, err := rmt.ListVersions("parent", "file.txt")
+
+		if err != nil {
+			t.Errorf("%s: expected the retry to succeed, got %v", c.name, err)
+		}
+
+		if server.Requests() != 2 {
+			t.Errorf("%s: expected 2 requests, got %d", c.name, server.Requests())
+		}
+	}
+}
+
+func TestRetryAfterIsHonored(t *testing.T) {
+	server, rmt := newTestRemote(t)
+	server.FailNext(fakedrive.Failure{Status: http.StatusTooManyRequests, RetryAfter: "1"})
+
+	started := time.Now()
+
+	_, err := rmt.ListVersions("parent", "file.txt")
+
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+
+	if server.Requests() != 2 {
+		t.Errorf("expected 2 requests, got %d", server.Requests())
+	}
+
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("expected to wait the second Retry-After asked for, waited %v", elapsed)
+	}
+}
+
+func TestNotRetriedFailures(t *testing.T) {
+	cases := []struct {
+		name    string
+		failure fakedrive.Failure
+	}{
+		{"not found", fakedrive.Failure{Status: http.StatusNotFound, Reason: "notFound"}},
+		{"forbidden", fakedrive.Failure{Status: http.StatusForbidden, Reason: "insufficientFilePermissions"}},
+	}
+
+	for _, c := range cases {
+		server, rmt := newTestRemote(t)
+		server.FailNext(c.failure)
+
+		_, err := rmt.ListVersions("parent", "file.txt")
+
+		if err == nil {
+			t.Errorf("%s: expected the failure to be returned", c.name)
+		}
+
+		if server.Requests() != 1 {
+			t.Errorf("%s: expected a single request, got %d", c.name, server.Requests())
+		}
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	server, rmt := newTestRemote(t)
+
+	for i := 0; i <= testPolicy.MaxRetries; i++ {
+		server.FailNext(fakedrive.Failure{Status: http.StatusInternalServerError})
+	}
+
+	_, err := rmt.ListVersions("parent", "file.txt")
+
+	if err == nil || !strings.Contains(err.Error(), "gave up after 2 retries") {
+		t.Errorf("expected to give up after 2 retries, got %v", err)
+	}
+
+	if server.Requests() != testPolicy.MaxRetries+1 {
+		t.Errorf("expected %d requests, got %d", testPolicy.MaxRetries+1, server.Requests())
+	}
+}
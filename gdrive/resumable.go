@@ -0,0 +1,305 @@
+package gdrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MinChunkSize is the granularity of resumable uploads, every chunk but the last is a multiple of it
+const MinChunkSize = 256 * 1024
+const DefaultChunkSize = 32 * MinChunkSize
+
+// errSessionExpired is returned once drive forgot an upload session, the upload has to start over
+var errSessionExpired = errors.New("upload session expired")
+
+// ResumableUploads sends files bigger than a chunk with the drive resumable upload protocol. The session
+// is saved after every chunk, so an upload interrupted even by the process exiting resumes on the next run
+type ResumableUploads struct {
+	client    *http.Client
+	uploadURL string
+	sessions  metadata.UploadSessionStore
+	chunkSize int64
+}
+
+// NewResumableUploads needs the client srv was created with, the drive service doesn't expose it
+func NewResumableUploads(client *http.Client, srv *drive.Service, sessions metadata.UploadSessionStore, chunkSize int64) (*ResumableUploads, error) {
+	if chunkSize <= 0 || chunkSize%MinChunkSize != 0 {
+		return nil, fmt.Errorf("chunk size %d isn't a multiple of %d", chunkSize, MinChunkSize)
+	}
+
+	return &ResumableUploads{
+		client:    client,
+		uploadURL: googleapi.ResolveRelative(srv.BasePath, "/upload/drive/v3/files"),
+		sessions:  sessions,
+		chunkSize: chunkSize}, nil
+}
+
+// uploadResponse reads how much of the content the server has from a 308, or the file once the upload is complete
+func uploadResponse(res *http.Response) (int64, *drive.File, error) {
+	defer func() {
+		err := res.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close upload response: %v", err)
+		}
+	}()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		file := &drive.File{}
+		err := json.NewDecoder(res.Body).Decode(file)
+
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decode uploaded file: %v", err)
+		}
+
+		return 0, file, nil
+	case http.StatusPermanentRedirect:
+		// No range means nothing was received yet
+		received := res.Header.Get("Range")
+
+		if received == "" {
+			return 0, nil, nil
+		}
+
+		last, err := strconv.ParseInt(received[strings.LastIndex(received, "-")+1:], 10, 64)
+
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid upload range '%s': %v", received, err)
+		}
+
+		return last + 1, nil, nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, nil, errSessionExpired
+	}
+
+	return 0, nil, googleapi.CheckResponse(res)
+}
+
+// start opens an upload session for a new file, returning its uri
+func (u *ResumableUploads) start(meta *drive.File, size int64) (string, error) {
+	body, err := json.Marshal(meta)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to encode file metadata: %v", err)
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		u.uploadURL+"?uploadType=resumable&fields="+
+			"id,name,modifiedTime,properties,md5Checksum,size",
+		bytes.NewReader(body))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	res, err := u.client.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		err := res.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close upload response: %v", err)
+		}
+	}()
+
+	err = googleapi.CheckResponse(res)
+
+	if err != nil {
+		return "", err
+	}
+
+	uri := res.Header.Get("Location")
+
+	if uri == "" {
+		return "", errors.New("no upload session uri in the response")
+	}
+
+	return uri, nil
+}
+
+// status asks how much of the content the session has
+func (u *ResumableUploads) status(uri string, size int64) (int64, *drive.File, error) {
+	req, err := http.NewRequest(http.MethodPut, uri, nil)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+
+	res, err := u.client.Do(req)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uploadResponse(res)
+}
+
+// sendChunk sends a chunk of the content from offset, content starts at start
+func (u *ResumableUploads) sendChunk(uri string, content io.ReadSeeker, start int64, offset int64, size int64) (int64, *drive.File, error) {
+	_, err := content.Seek(start+offset, io.SeekStart)
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to seek content: %v", err)
+	}
+
+	length := size - offset
+
+	if length > u.chunkSize {
+		length = u.chunkSize
+	}
+
+	req, err := http.NewRequest(http.MethodPut, uri, io.LimitReader(content, length))
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.ContentLength = length
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+
+	res, err := u.client.Do(req)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uploadResponse(res)
+}
+
+// resumableUpload uploads the content in chunks, resuming the saved session of the same file and content if there is one
+func (d *DriveRemote) resumableUpload(meta *drive.File, content io.ReadSeeker, start int64, size int64, hash string) (*drive.File, error) {
+	u := d.uploads
+	key := metadata.UploadSessionKey(meta.Parents[0], meta.Name)
+	// The session was started with its metadata, a different signature can't be set by resuming it
+	signatureId := meta.Properties["gpg"]
+
+	exists, session, err := u.sessions.GetUploadSession(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	var file *drive.File
+
+	if exists && session.Hash == hash && session.Size == size && session.SignatureId == signatureId {
+		log.Printf("resuming upload of %s from %d of %d bytes", meta.Name, session.Offset, size)
+
+		err = d.retry.Do(func() (err error) {
+			offset, file, err = u.status(session.URI, size)
+			return err
+		}, retryable)
+
+		if err == errSessionExpired {
+			exists = false
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get upload session status: %v", err)
+		}
+	} else {
+		exists = false
+	}
+
+	if !exists {
+		var uri string
+
+		err = d.retry.Do(func() (err error) {
+			uri, err = u.start(meta, size)
+			return err
+		}, retryable)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to start upload session: %v", err)
+		}
+
+		session = metadata.UploadSession{URI: uri, Hash: hash, Size: size, SignatureId: signatureId}
+		offset = 0
+
+		err = u.sessions.SetUploadSession(key, session)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for file == nil {
+		var attempt = 0
+
+		err = d.retry.Do(func() error {
+			// A failed chunk may have been partly received
+			if attempt > 0 {
+				received, done, err := u.status(session.URI, size)
+
+				if err != nil {
+					return err
+				}
+
+				offset, file = received, done
+
+				if file != nil {
+					return nil
+				}
+			}
+
+			attempt++
+
+			received, done, err := u.sendChunk(session.URI, content, start, offset, size)
+
+			if err != nil {
+				return err
+			}
+
+			offset, file = received, done
+
+			return nil
+		}, retryable)
+
+		if err == errSessionExpired {
+			removeErr := u.sessions.RemoveUploadSession(key)
+
+			if removeErr != nil {
+				log.Printf("failed to remove expired upload session: %v", removeErr)
+			}
+
+			return nil, fmt.Errorf("upload session expired at %d of %d bytes, the next upload starts over", offset, size)
+		} else if err != nil {
+			return nil, fmt.Errorf("upload interrupted at %d of %d bytes, the next upload resumes: %v", offset, size, err)
+		}
+
+		session.Offset = offset
+
+		err = u.sessions.SetUploadSession(key, session)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.sessions.RemoveUploadSession(key)
+
+	if err != nil {
+		log.Printf("failed to remove finished upload session: %v", err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,39 @@
+package gdrive
+
+import (
+	"github.com/ilyail3/fileSync/retry"
+	"google.golang.org/api/googleapi"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// retryable retries rate limits, server errors and network failures
+func retryable(err error) (bool, time.Duration) {
+	if apiErr, ok := err.(*googleapi.Error); ok {
+		after := retry.ParseRetryAfter(apiErr.Header.Get("Retry-After"), time.Now())
+
+		if apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500 {
+			return true, after
+		}
+
+		if apiErr.Code == http.StatusForbidden {
+			for _, item := range apiErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true, after
+				}
+			}
+		}
+
+		return false, 0
+	}
+
+	if urlErr, ok := err.(*url.Error); ok {
+		_, isNetErr := urlErr.Err.(net.Error)
+
+		return isNetErr, 0
+	}
+
+	return false, 0
+}
@@ -107,7 +107,8 @@ func findPath(execDirName string, fileName string) string {
 	return execName
 }
 
-func NewService(dirName string) (*drive.Service, error) {
+// NewClient returns the authorized http client for drive, asking for a token the first time
+func NewClient(dirName string) (*http.Client, error) {
 	b, err := ioutil.ReadFile(findPath(dirName, "credentials.json"))
 	if err != nil {
 		return nil, fmt.Errorf("unable to read client secret file: %v", err)
@@ -125,6 +126,16 @@ func NewService(dirName string) (*drive.Service, error) {
 		return nil, fmt.Errorf("failed to get client: %v", err)
 	}
 
+	return client, nil
+}
+
+func NewService(dirName string) (*drive.Service, error) {
+	client, err := NewClient(dirName)
+
+	if err != nil {
+		return nil, err
+	}
+
 	srv, err := drive.New(client)
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve drive client: %v", err)
@@ -0,0 +1,205 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is read from every directory of a tracked tree, its patterns apply to the files below it
+const FileName = ".syncignore"
+
+// globEscaper keeps the glob characters of a file name literal in a pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+// Matcher holds the patterns of every ignore file loaded so far, later patterns take precedence
+type Matcher struct {
+	patterns []pattern
+}
+
+func NewMatcher() *Matcher {
+	return &Matcher{patterns: make([]pattern, 0)}
+}
+
+// AddPatterns reads gitignore style lines, base is the slash separated directory they are relative to
+func (m *Matcher) AddPatterns(base string, content io.Reader) error {
+	scanner := bufio.NewScanner(content)
+
+	for scanner.Scan() {
+		p, ok, err := parsePattern(base, scanner.Text())
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return fmt.Errorf("failed to read patterns: %v", err)
+	}
+
+	return nil
+}
+
+// AddFile loads the ignore file of directory dir inside root, a missing file adds no patterns
+func (m *Matcher) AddFile(root string, dir string) error {
+	fh, err := os.Open(filepath.Join(root, filepath.FromSlash(dir), FileName))
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open ignore file: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close ignore file: %v", err)
+		}
+	}()
+
+	err = m.AddPatterns(dir, fh)
+
+	if err != nil {
+		return fmt.Errorf("failed to load %s in '%s': %v", FileName, dir, err)
+	}
+
+	return nil
+}
+
+func (m *Matcher) matchOne(relPath string, isDir bool) bool {
+	var ignored = false
+
+	for i := range m.patterns {
+		if m.patterns[i].matches(relPath, isDir) {
+			ignored = !m.patterns[i].negate
+		}
+	}
+
+	return ignored
+}
+
+// Match reports if the slash separated path relative to the tracked root is ignored,
+// like git a file can't be re-included once one of its parent directories is excluded
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	names := strings.Split(path.Clean(relPath), "/")
+
+	for i := 1; i < len(names); i++ {
+		if m.matchOne(strings.Join(names[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return m.matchOne(strings.Join(names, "/"), isDir)
+}
+
+// LoadMatcher loads the ignore files of root and of every directory from it down to dirName
+func LoadMatcher(root string, dirName string) (*Matcher, error) {
+	m := NewMatcher()
+	relPath, err := filepath.Rel(root, dirName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.AddFile(root, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var dir = ""
+
+	for _, name := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if name == "." {
+			continue
+		}
+
+		dir = strings.TrimPrefix(dir+"/"+name, "/")
+		err = m.AddFile(root, dir)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Excluded reports if the file fullAddress inside the tracked directory root is ignored, it doesn't have to exist
+func Excluded(root string, fullAddress string) (bool, error) {
+	m, err := LoadMatcher(root, filepath.Dir(fullAddress))
+
+	if err != nil {
+		return false, err
+	}
+
+	relPath, err := filepath.Rel(root, fullAddress)
+
+	if err != nil {
+		return false, err
+	}
+
+	return m.Match(filepath.ToSlash(relPath), false), nil
+}
+
+// Exclude appends a pattern matching only the file fullAddress to the ignore file of the tracked directory root
+func Exclude(root string, fullAddress string) error {
+	relPath, err := filepath.Rel(root, fullAddress)
+
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimRight(relPath, " \t\r") != relPath {
+		return fmt.Errorf("can't exclude %s, patterns can't end with spaces", fullAddress)
+	}
+
+	address := filepath.Join(root, FileName)
+	content, err := ioutil.ReadFile(address)
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read ignore file: %v", err)
+	}
+
+	// The leading slash anchors the pattern to root
+	line := "/" + globEscaper.Replace(filepath.ToSlash(relPath)) + "\n"
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		line = "\n" + line
+	}
+
+	fh, err := os.OpenFile(address, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+
+	if err != nil {
+		return fmt.Errorf("failed to open ignore file: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close ignore file: %v", err)
+		}
+	}()
+
+	_, err = fh.WriteString(line)
+
+	if err != nil {
+		return fmt.Errorf("failed to write ignore file: %v", err)
+	}
+
+	return nil
+}
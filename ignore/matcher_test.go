@@ -0,0 +1,252 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type matchCase struct {
+	relPath string
+	isDir   bool
+	ignored bool
+}
+
+func newTestMatcher(t *testing.T, base string, patterns string) *Matcher {
+	m := NewMatcher()
+
+	err := m.AddPatterns(base, strings.NewReader(patterns))
+
+	if err != nil {
+		t.Fatalf("failed to add patterns: %v", err)
+	}
+
+	return m
+}
+
+func checkMatches(t *testing.T, name string, m *Matcher, cases []matchCase) {
+	for _, c := range cases {
+		if m.Match(c.relPath, c.isDir) != c.ignored {
+			t.Errorf("%s: expected %s (dir %v) ignored to be %v", name, c.relPath, c.isDir, c.ignored)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		cases    []matchCase
+	}{
+		{
+			name:     "unanchored matches at any depth",
+			patterns: "*.tmp\ncache\n",
+			cases: []matchCase{
+				{"file.tmp", false, true},
+				{"a/b/file.tmp", false, true},
+				{"file.tmp.txt", false, false},
+				{"cache", false, true},
+				{"a/cache", true, true},
+				{"a/cache/file.txt", false, true},
+				{"a/cached", false, false}}},
+		{
+			name:     "anchored matches from the ignore file directory",
+			patterns: "/build\ndocs/*.pdf\n",
+			cases: []matchCase{
+				{"build", true, true},
+				{"build/out.o", false, true},
+				{"src/build", true, false},
+				{"docs/manual.pdf", false, true},
+				{"docs/old/manual.pdf", false, false},
+				{"src/docs/manual.pdf", false, false}}},
+		{
+			name:     "directory only",
+			patterns: "out/\n",
+			cases: []matchCase{
+				{"out", true, true},
+				{"out", false, false},
+				{"src/out", true, true},
+				{"src/out/file.txt", false, true},
+				{"src/out.txt", false, false}}},
+		{
+			name:     "negation",
+			patterns: "*.log\n!keep.log\n",
+			cases: []matchCase{
+				{"debug.log", false, true},
+				{"keep.log", false, false},
+				{"a/keep.log", false, false}}},
+		{
+			name:     "later patterns take precedence",
+			patterns: "!keep.log\n*.log\n",
+			cases: []matchCase{
+				{"keep.log", false, true}}},
+		{
+			name:     "excluded directories can't be re-included from",
+			patterns: "logs/\n!logs/keep.log\n",
+			cases: []matchCase{
+				{"logs/keep.log", false, true},
+				{"logs/other.log", false, true}}},
+		{
+			name:     "leading **",
+			patterns: "**/logs\n",
+			cases: []matchCase{
+				{"logs", true, true},
+				{"a/b/logs", true, true},
+				{"a/b/logs/x.txt", false, true},
+				{"a/b/logsx", true, false}}},
+		{
+			name:     "middle **",
+			patterns: "a/**/b\n",
+			cases: []matchCase{
+				{"a/b", false, true},
+				{"a/x/b", false, true},
+				{"a/x/y/b", false, true},
+				{"a/x/c", false, false},
+				{"x/a/b", false, false}}},
+		{
+			name:     "trailing ** matches inside only",
+			patterns: "foo/**\n",
+			cases: []matchCase{
+				{"foo", true, false},
+				{"foo", false, false},
+				{"foo/file.txt", false, true},
+				{"foo/a/b/file.txt", false, true},
+				{"bar/foo/file.txt", false, false}}},
+		{
+			name:     "comments, blank lines and escapes",
+			patterns: "# comment\n\n   \n\\#hash\n\\!bang\ntrailing   \n",
+			cases: []matchCase{
+				{"# comment", false, false},
+				{"#hash", false, true},
+				{"!bang", false, true},
+				{"trailing", false, true}}},
+	}
+
+	for _, test := range tests {
+		checkMatches(t, test.name, newTestMatcher(t, "", test.patterns), test.cases)
+	}
+}
+
+func TestMatchNestedBase(t *testing.T) {
+	m := newTestMatcher(t, "", "*.bak\n")
+
+	err := m.AddPatterns("sub", strings.NewReader("/local\n!important.bak\n"))
+
+	if err != nil {
+		t.Fatalf("failed to add patterns: %v", err)
+	}
+
+	checkMatches(t, "nested", m, []matchCase{
+		{"local", false, false},
+		{"sub/local", false, true},
+		{"sub/deeper/local", false, false},
+		{"old.bak", false, true},
+		{"important.bak", false, true},
+		{"sub/important.bak", false, false},
+		{"sub/deeper/important.bak", false, false}})
+}
+
+func TestInvalidPattern(t *testing.T) {
+	err := NewMatcher().AddPatterns("", strings.NewReader("ok\n[unclosed\n"))
+
+	if err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestAddFile(t *testing.T) {
+	root := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(root, "sub"), 0750)
+
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(root, "sub", FileName), []byte("*.tmp\n"), 0640)
+
+	if err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	m := NewMatcher()
+
+	for _, dir := range []string{"", "sub"} {
+		err = m.AddFile(root, dir)
+
+		if err != nil {
+			t.Fatalf("failed to load ignore file of '%s': %v", dir, err)
+		}
+	}
+
+	checkMatches(t, "ignore file", m, []matchCase{
+		{"sub/file.tmp", false, true},
+		{"file.tmp", false, false}})
+}
+
+func TestExcludedMissingFile(t *testing.T) {
+	root := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(root, FileName), []byte("deleted.txt\nold/\n"), 0640)
+
+	if err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	// None of the files exist, like files deleted after they were synced
+	for fullAddress, expected := range map[string]bool{
+		filepath.Join(root, "deleted.txt"):            true,
+		filepath.Join(root, "sub", "deleted.txt"):     true,
+		filepath.Join(root, "old", "file.txt"):        true,
+		filepath.Join(root, "sub", "old", "file.txt"): true,
+		filepath.Join(root, "kept.txt"):               false} {
+		excluded, err := Excluded(root, fullAddress)
+
+		if err != nil {
+			t.Fatalf("failed to match %s: %v", fullAddress, err)
+		}
+
+		if excluded != expected {
+			t.Errorf("expected %s excluded to be %v", fullAddress, expected)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	root := t.TempDir()
+
+	// An ignore file without a final newline must keep its last pattern
+	err := ioutil.WriteFile(filepath.Join(root, FileName), []byte("*.tmp"), 0640)
+
+	if err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	for _, name := range []string{"sub/notes.txt", "sub/[draft] *.txt"} {
+		err = Exclude(root, filepath.Join(root, filepath.FromSlash(name)))
+
+		if err != nil {
+			t.Fatalf("failed to exclude %s: %v", name, err)
+		}
+	}
+
+	for name, expected := range map[string]bool{
+		"sub/notes.txt":        true,
+		"sub/[draft] *.txt":    true,
+		"sub/d all.txt":        false,
+		"notes.txt":            false,
+		"sub/deeper/notes.txt": false,
+		"sub/file.tmp":         true} {
+		excluded, err := Excluded(root, filepath.Join(root, filepath.FromSlash(name)))
+
+		if err != nil {
+			t.Fatalf("failed to match %s: %v", name, err)
+		}
+
+		if excluded != expected {
+			t.Errorf("expected %s excluded to be %v", name, expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// pattern is a single gitignore style line, its segments are matched against the path relative to base
+type pattern struct {
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parsePattern returns false for blank lines and comments
+func parsePattern(base string, line string) (pattern, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+
+	p := pattern{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash anywhere but the end anchors the pattern to the directory of the ignore file,
+	// otherwise it matches a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimLeft(line, "/")
+
+	if line == "" {
+		return pattern{}, false, nil
+	}
+
+	if !anchored {
+		p.segments = append(p.segments, "**")
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		if segment == "" {
+			continue
+		}
+
+		_, err := path.Match(segment, "")
+
+		if err != nil {
+			return pattern{}, false, fmt.Errorf("invalid pattern '%s': %v", line, err)
+		}
+
+		p.segments = append(p.segments, segment)
+	}
+
+	return p, true, nil
+}
+
+// matches reports if the slash separated path, relative to the tracked root, is matched by the pattern
+func (p *pattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.base != "" {
+		if !strings.HasPrefix(relPath, p.base+"/") {
+			return false
+		}
+
+		relPath = strings.TrimPrefix(relPath, p.base+"/")
+	}
+
+	return matchSegments(p.segments, strings.Split(relPath, "/"))
+}
+
+func matchSegments(segments []string, names []string) bool {
+	if len(segments) == 0 {
+		return len(names) == 0
+	}
+
+	if segments[0] == "**" {
+		// A trailing ** matches everything inside, like git foo/** doesn't match foo itself
+		if len(segments) == 1 {
+			return len(names) > 0
+		}
+
+		// ** consumes any number of directories, including none
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(segments[1:], names[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(segments[0], names[0])
+
+	if err != nil || !matched {
+		return false
+	}
+
+	return matchSegments(segments[1:], names[1:])
+}
@@ -0,0 +1,44 @@
+package localdir
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FileSystem is the set of file operations the directory remote needs, it lets the same
+// layout be kept on a local mount or on any other file tree
+type FileSystem interface {
+	MkdirAll(name string) error
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	Remove(name string) error
+	Rename(oldName, newName string) error
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) MkdirAll(name string) error {
+	return os.MkdirAll(name, 0700)
+}
+
+func (osFileSystem) Create(name string) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+}
+
+func (osFileSystem) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (osFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
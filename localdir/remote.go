@@ -0,0 +1,269 @@
+package localdir
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const sidecarSuffix = ".json"
+
+// sidecar is the metadata stored next to every version content file
+type sidecar struct {
+	Name         string            `json:"name"`
+	ModifiedTime string            `json:"modifiedTime"`
+	Properties   map[string]string `json:"properties"`
+	Md5Checksum  string            `json:"md5Checksum"`
+	Size         int64             `json:"size"`
+}
+
+// DirectoryRemote keeps every version of a file under <folder>/<name>.versions/<id>, with the
+// version metadata in a <id>.json sidecar. Folder and version ids are paths relative to the root
+type DirectoryRemote struct {
+	fs   FileSystem
+	root string
+}
+
+func NewDirectoryRemote(root string) (*DirectoryRemote, error) {
+	stat, err := os.Stat(root)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat sync directory: %v", err)
+	}
+
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("sync directory %s is not a directory", root)
+	}
+
+	return NewFileSystemRemote(osFileSystem{}, root), nil
+}
+
+func NewFileSystemRemote(fs FileSystem, root string) *DirectoryRemote {
+	return &DirectoryRemote{fs: fs, root: root}
+}
+
+func (d *DirectoryRemote) fullPath(id string) string {
+	return path.Join(d.root, id)
+}
+
+// writeFile stores content under id and returns its md5 and size
+func (d *DirectoryRemote) writeFile(id string, content io.Reader) (string, int64, error) {
+	tmpName := d.fullPath(id) + ".tmp"
+
+	fh, err := d.fs.Create(tmpName)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create %s: %v", tmpName, err)
+	}
+
+	hash := md5.New()
+	size, err := io.Copy(fh, io.TeeReader(content, hash))
+
+	if err != nil {
+		closeErr := fh.Close()
+
+		if closeErr != nil {
+			log.Printf("failed to close %s: %v", tmpName, closeErr)
+		}
+
+		return "", 0, fmt.Errorf("failed to write %s: %v", tmpName, err)
+	}
+
+	err = fh.Close()
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to close %s: %v", tmpName, err)
+	}
+
+	err = d.fs.Rename(tmpName, d.fullPath(id))
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to rename %s: %v", tmpName, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+func (d *DirectoryRemote) readSidecar(id string) (*remote.Version, error) {
+	fh, err := d.fs.Open(d.fullPath(id + sidecarSuffix))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata for %s: %v", id, err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close metadata file: %v", err)
+		}
+	}()
+
+	var mt sidecar
+
+	err = json.NewDecoder(fh).Decode(&mt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata for %s: %v", id, err)
+	}
+
+	mTime, err := time.Parse(time.RFC3339, mt.ModifiedTime)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modified time '%s': %v", mt.ModifiedTime, err)
+	}
+
+	if mt.Properties == nil {
+		mt.Properties = make(map[string]string)
+	}
+
+	return &remote.Version{
+		Id:           id,
+		Name:         mt.Name,
+		ModifiedTime: mTime,
+		Properties:   mt.Properties,
+		Md5Checksum:  mt.Md5Checksum,
+		Size:         mt.Size}, nil
+}
+
+func (d *DirectoryRemote) GetOrCreateDirectory(parentId string, directoryName string) (string, error) {
+	id := path.Join(parentId, directoryName)
+
+	err := d.fs.MkdirAll(d.fullPath(id))
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create sync folder: %v", err)
+	}
+
+	return id, nil
+}
+
+func (d *DirectoryRemote) FindDirectory(parentId string, directoryName string) (string, bool, error) {
+	id := path.Join(parentId, directoryName)
+
+	_, err := d.fs.ReadDir(d.fullPath(id))
+
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("failed to lookup sync folder: %v", err)
+	}
+
+	return id, true, nil
+}
+
+func (d *DirectoryRemote) ListVersions(parentId string, fileName string) ([]*remote.Version, error) {
+	versionsDir := remote.VersionsFolder(parentId, fileName)
+	versions := make([]*remote.Version, 0)
+
+	entries, err := d.fs.ReadDir(d.fullPath(versionsDir))
+
+	if os.IsNotExist(err) {
+		return versions, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %v", fileName, err)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), sidecarSuffix) {
+			continue
+		}
+
+		version, err := d.readSidecar(path.Join(versionsDir, strings.TrimSuffix(entry.Name(), sidecarSuffix)))
+
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (d *DirectoryRemote) Upload(parentId string, fileName string, modTime time.Time, properties map[string]string, content io.Reader) (*remote.Version, error) {
+	modTime = remote.VersionTime(modTime)
+
+	err := d.fs.MkdirAll(d.fullPath(remote.VersionsFolder(parentId, fileName)))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create versions folder: %v", err)
+	}
+
+	id, err := remote.NewVersionId(parentId, fileName, modTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, size, err := d.writeFile(id, content)
+
+	if err != nil {
+		return nil, fmt.Errorf("upload operation failed: %v", err)
+	}
+
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+
+	mt, err := json.Marshal(sidecar{
+		Name:         fileName,
+		ModifiedTime: modTime.UTC().Format(time.RFC3339),
+		Properties:   properties,
+		Md5Checksum:  checksum,
+		Size:         size})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %v", err)
+	}
+
+	// The sidecar is written last, a version without one is never listed
+	_, _, err = d.writeFile(id+sidecarSuffix, strings.NewReader(string(mt)))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %v", err)
+	}
+
+	return &remote.Version{
+		Id:           id,
+		Name:         fileName,
+		ModifiedTime: modTime,
+		Properties:   properties,
+		Md5Checksum:  checksum,
+		Size:         size}, nil
+}
+
+func (d *DirectoryRemote) Download(id string) (io.ReadCloser, error) {
+	fh, err := d.fs.Open(d.fullPath(id))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %v", id, err)
+	}
+
+	return fh, nil
+}
+
+func (d *DirectoryRemote) Delete(id string) error {
+	// Remove the sidecar first so a partially deleted version is no longer listed
+	err := d.fs.Remove(d.fullPath(id + sidecarSuffix))
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete metadata of %s: %v", id, err)
+	}
+
+	err = d.fs.Remove(d.fullPath(id))
+
+	if err != nil {
+		return fmt.Errorf("failed to delete file %s: %v", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package localdir
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func newTestRemote(t *testing.T) (*DirectoryRemote, string) {
+	root := t.TempDir()
+
+	rmt, err := NewDirectoryRemote(root)
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	return rmt, root
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	rmt, root := newTestRemote(t)
+	content := []byte("some file content")
+	modTime := time.Date(2020, 5, 17, 10, 30, 15, 500, time.UTC)
+	properties := map[string]string{"host": "laptop", "mode": "0644"}
+
+	uploaded, err := rmt.Upload("folder", "file.txt", modTime, properties, bytes.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if path.Dir(uploaded.Id) != "folder/file.txt.versions" {
+		t.Errorf("expected the version under folder/file.txt.versions, got %s", uploaded.Id)
+	}
+
+	if _, err := os.Stat(path.Join(root, uploaded.Id+sidecarSuffix)); err != nil {
+		t.Errorf("expected a metadata sidecar next to the version: %v", err)
+	}
+
+	versions, err := rmt.ListVersions("folder", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(versions))
+	}
+
+	version := versions[0]
+	sum := md5.Sum(content)
+
+	if version.Id != uploaded.Id || version.Name != "file.txt" {
+		t.Errorf("expected version %s of file.txt, got %s of %s", uploaded.Id, version.Id, version.Name)
+	}
+
+	if !version.ModifiedTime.Equal(modTime.Truncate(time.Second)) {
+		t.Errorf("expected modified time %v, got %v", modTime.Truncate(time.Second), version.ModifiedTime)
+	}
+
+	if version.Md5Checksum != hex.EncodeToString(sum[:]) || version.Size != int64(len(content)) {
+		t.Errorf("expected checksum %x and size %d, got %s and %d", sum, len(content), version.Md5Checksum, version.Size)
+	}
+
+	for key, value := range properties {
+		if version.Properties[key] != value {
+			t.Errorf("expected property %s=%s, got '%s'", key, value, version.Properties[key])
+		}
+	}
+
+	body, err := rmt.Download(version.Id)
+
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	downloaded, err := ioutil.ReadAll(body)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	err = body.Close()
+
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("expected %q, got %q", content, downloaded)
+	}
+}
+
+func TestListVersionsOrder(t *testing.T) {
+	rmt, _ := newTestRemote(t)
+	start := time.Date(2020, 5, 17, 10, 0, 0, 0, time.UTC)
+
+	// Uploaded out of order, listing follows the modified time the ids start with
+	for _, hours := range []int{2, 0, 1} {
+		_, err := rmt.Upload("folder", "file.txt", start.Add(time.Duration(hours)*time.Hour), nil, bytes.NewReader([]byte("content")))
+
+		if err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+	}
+
+	_, err := rmt.Upload("folder", "other.txt", start, nil, bytes.NewReader([]byte("other")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	versions, err := rmt.ListVersions("folder", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions of file.txt, got %d", len(versions))
+	}
+
+	for i, version := range versions {
+		if !version.ModifiedTime.Equal(start.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("expected version %d modified at %v, got %v", i, start.Add(time.Duration(i)*time.Hour), version.ModifiedTime)
+		}
+	}
+
+	missing, err := rmt.ListVersions("folder", "missing.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(missing) != 0 {
+		t.Errorf("expected no versions of a file never uploaded, got %d", len(missing))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	rmt, root := newTestRemote(t)
+	modTime := time.Date(2020, 5, 17, 10, 0, 0, 0, time.UTC)
+
+	kept, err := rmt.Upload("folder", "file.txt", modTime, nil, bytes.NewReader([]byte("kept")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	deleted, err := rmt.Upload("folder", "file.txt", modTime.Add(time.Hour), nil, bytes.NewReader([]byte("deleted")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	err = rmt.Delete(deleted.Id)
+
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	for _, name := range []string{deleted.Id, deleted.Id + sidecarSuffix} {
+		if _, err := os.Stat(path.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, stat returned %v", name, err)
+		}
+	}
+
+	versions, err := rmt.ListVersions("folder", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 1 || versions[0].Id != kept.Id {
+		t.Errorf("expected only %s to be left, got %d versions", kept.Id, len(versions))
+	}
+
+	err = rmt.Delete(deleted.Id)
+
+	if err == nil {
+		t.Errorf("expected deleting a missing version to fail")
+	}
+}
+
+func TestMissingSidecar(t *testing.T) {
+	rmt, root := newTestRemote(t)
+
+	version, err := rmt.Upload("folder", "file.txt", time.Now(), nil, bytes.NewReader([]byte("content")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// An upload interrupted before its sidecar was written leaves the content alone
+	err = os.Remove(path.Join(root, version.Id+sidecarSuffix))
+
+	if err != nil {
+		t.Fatalf("failed to remove sidecar: %v", err)
+	}
+
+	versions, err := rmt.ListVersions("folder", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 0 {
+		t.Errorf("expected a version without a sidecar not to be listed, got %d", len(versions))
+	}
+
+	err = rmt.Delete(version.Id)
+
+	if err != nil {
+		t.Errorf("expected the version without a sidecar to be deleted: %v", err)
+	}
+
+	if _, err := os.Stat(path.Join(root, version.Id)); !os.IsNotExist(err) {
+		t.Errorf("expected the content to be removed, stat returned %v", err)
+	}
+}
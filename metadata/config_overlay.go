@@ -0,0 +1,37 @@
+package metadata
+
+import "sync"
+
+// ConfigOverlay reads through to a ConfigStore but keeps writes in memory, so settings given
+// to a run that must not change anything still apply to that run. It's safe for concurrent use
+type ConfigOverlay struct {
+	store ConfigStore
+
+	mutex  sync.Mutex
+	values map[string]string
+}
+
+func NewConfigOverlay(store ConfigStore) *ConfigOverlay {
+	return &ConfigOverlay{store: store, values: make(map[string]string)}
+}
+
+func (c *ConfigOverlay) ReadStringConfig(key string) (bool, string, error) {
+	c.mutex.Lock()
+	value, exists := c.values[key]
+	c.mutex.Unlock()
+
+	if exists {
+		return true, value, nil
+	}
+
+	return c.store.ReadStringConfig(key)
+}
+
+func (c *ConfigOverlay) WriteStringConfig(key, value string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.values[key] = value
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 )
 
+// SqliteMetadataStore is safe for concurrent use
 type SqliteMetadataStore struct {
 	db                *sql.DB
 	getQuery          *sql.Stmt
@@ -39,8 +40,10 @@ func (s *SqliteMetadataStore) Get(fileAddress string) (bool, FileMetadata, error
 
 	var remoteModDate string
 	var localModDate string
+	var hash sql.NullString
+	var size sql.NullInt64
 
-	err = result.Scan(&remoteModDate, &localModDate)
+	err = result.Scan(&remoteModDate, &localModDate, &hash, &size)
 
 	if err != nil {
 		return false, FileMetadata{}, fmt.Errorf("failed to scan get query results: %v", err)
@@ -58,14 +61,18 @@ func (s *SqliteMetadataStore) Get(fileAddress string) (bool, FileMetadata, error
 		return false, FileMetadata{}, fmt.Errorf("failed to parse value '%s': %v", localModDate, err)
 	}
 
-	return true, FileMetadata{LocalModDate: localModDateTime, RemoteModDate: remoteModDateTime}, nil
+	return true, FileMetadata{
+		LocalModDate:  localModDateTime,
+		RemoteModDate: remoteModDateTime,
+		Hash:          hash.String,
+		Size:          size.Int64}, nil
 }
 
 func (s *SqliteMetadataStore) Set(fileAddress string, metadata FileMetadata) error {
 	mtStringRemote := metadata.RemoteModDate.UTC().Format(time.RFC3339)
 	mtStringLocal := metadata.LocalModDate.UTC().Format(time.RFC3339)
 
-	result, err := s.putQuery.Exec(fileAddress, mtStringRemote, mtStringLocal)
+	result, err := s.putQuery.Exec(fileAddress, mtStringRemote, mtStringLocal, metadata.Hash, metadata.Size)
 
 	if err != nil {
 		return fmt.Errorf("failed to write metadata: %v", err)
@@ -84,6 +91,17 @@ func (s *SqliteMetadataStore) Set(fileAddress string, metadata FileMetadata) err
 	return nil
 }
 
+// Remove forgets the sync state of the file
+func (s *SqliteMetadataStore) Remove(fileAddress string) error {
+	_, err := s.db.Exec("DELETE FROM sync_mt WHERE filename = ?", fileAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove metadata: %v", err)
+	}
+
+	return nil
+}
+
 func (s *SqliteMetadataStore) Close() error {
 	return s.db.Close()
 }
@@ -101,7 +119,7 @@ func (s *SqliteMetadataStore) GetAllSyncedFiles() ([]string, error) {
 		err := rows.Close()
 
 		if err != nil {
-			log.Printf("failed to close rows: %v")
+			log.Printf("failed to close rows: %v", err)
 		}
 	}()
 
@@ -124,13 +142,21 @@ func runQuery(db *sql.DB, query string) error {
 	statement, err := db.Prepare(query)
 
 	if err != nil {
-		return fmt.Errorf("failed to prepare mod_date create table query: %v", err)
+		return fmt.Errorf("failed to prepare query '%s': %v", query, err)
 	}
 
+	defer func() {
+		err := statement.Close()
+
+		if err != nil {
+			log.Printf("failed to close statement: %v", err)
+		}
+	}()
+
 	_, err = statement.Exec()
 
 	if err != nil {
-		return fmt.Errorf("failed to execute mod_date create table query: %v", err)
+		return fmt.Errorf("failed to execute query '%s': %v", query, err)
 	}
 
 	return nil
@@ -152,6 +178,42 @@ func createNewDatabase(db *sql.DB) error {
 	return nil
 }
 
+// migrations upgrade the schema created by createNewDatabase, the database user_version counts the applied ones
+var migrations = []string{
+	"ALTER TABLE sync_mt ADD COLUMN hash text",
+	"ALTER TABLE sync_mt ADD COLUMN size integer",
+	"CREATE TABLE tracked_dirs(dirname text primary key)",
+	"CREATE TABLE tracked_files(filename text primary key)",
+	"CREATE TABLE upload_sessions(upload_key text primary key, session_uri text, hash text, size integer, offset integer)",
+	"ALTER TABLE upload_sessions ADD COLUMN signature_id text NOT NULL DEFAULT ''",
+}
+
+func migrateDatabase(db *sql.DB) error {
+	var version int
+
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for ; version < len(migrations); version++ {
+		err = runQuery(db, migrations[version])
+
+		if err != nil {
+			return fmt.Errorf("failed to migrate schema to version %d: %v", version+1, err)
+		}
+
+		err = runQuery(db, fmt.Sprintf("PRAGMA user_version = %d", version+1))
+
+		if err != nil {
+			return fmt.Errorf("failed to update schema version: %v", err)
+		}
+	}
+
+	return nil
+}
+
 func NewSQLite3Store(dirName string) (*SqliteMetadataStore, error) {
 	fileName := path.Join(dirName, "sync.sqlite3")
 
@@ -169,11 +231,20 @@ func NewSQLite3Store(dirName string) (*SqliteMetadataStore, error) {
 		return nil, fmt.Errorf("failed to open sqlite3 database: %v", err)
 	}
 
+	// Files are synced concurrently, a single connection has sqlite see one statement at a time
+	// instead of failing writes with database is locked
+	database.SetMaxOpenConns(1)
+
+	// The database stays open for the store, close it only if the setup fails
+	var opened = false
+
 	defer func() {
-		err := database.Close()
+		if !opened {
+			err := database.Close()
 
-		if err != nil {
-			log.Printf("failed to close database: %v", err)
+			if err != nil {
+				log.Printf("failed to close database: %v", err)
+			}
 		}
 	}()
 
@@ -185,13 +256,19 @@ func NewSQLite3Store(dirName string) (*SqliteMetadataStore, error) {
 		}
 	}
 
-	getQuery, err := database.Prepare("SELECT remote_mod_date,local_mod_date FROM sync_mt WHERE filename = ?")
+	err = migrateDatabase(database)
+
+	if err != nil {
+		return nil, err
+	}
+
+	getQuery, err := database.Prepare("SELECT remote_mod_date,local_mod_date,hash,size FROM sync_mt WHERE filename = ?")
 
 	if err != nil {
 		log.Fatalf("Failed to prepare get query: %v", err)
 	}
 
-	putQuery, err := database.Prepare("INSERT OR REPLACE INTO sync_mt(filename, remote_mod_date, local_mod_date) VALUES (?, ?, ?)")
+	putQuery, err := database.Prepare("INSERT OR REPLACE INTO sync_mt(filename, remote_mod_date, local_mod_date, hash, size) VALUES (?, ?, ?, ?, ?)")
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare put query: %v", err)
@@ -209,6 +286,8 @@ func NewSQLite3Store(dirName string) (*SqliteMetadataStore, error) {
 		return nil, fmt.Errorf("failed to prepare put query: %v", err)
 	}
 
+	opened = true
+
 	return &SqliteMetadataStore{
 		db:                database,
 		getQuery:          getQuery,
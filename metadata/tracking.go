@@ -0,0 +1,116 @@
+package metadata
+
+import (
+	"fmt"
+	"log"
+)
+
+// TrackingStore keeps the files and directories to sync, files of a tracked directory are picked up on every run.
+// Files that were synced once stay tracked through their sync state
+type TrackingStore interface {
+	TrackFile(fileAddress string) error
+	UntrackFile(fileAddress string) error
+	GetTrackedFiles() ([]string, error)
+	TrackDirectory(dirName string) error
+	UntrackDirectory(dirName string) error
+	GetTrackedDirectories() ([]string, error)
+}
+
+func (s *SqliteMetadataStore) exec(query string, value string) error {
+	_, err := s.db.Exec(query, value)
+
+	return err
+}
+
+func (s *SqliteMetadataStore) queryStrings(query string) ([]string, error) {
+	values := make([]string, 0)
+
+	rows, err := s.db.Query(query)
+
+	if err != nil {
+		return values, err
+	}
+
+	defer func() {
+		err := rows.Close()
+
+		if err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var value string
+
+	for rows.Next() {
+		err = rows.Scan(&value)
+
+		if err != nil {
+			return values, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func (s *SqliteMetadataStore) TrackFile(fileAddress string) error {
+	err := s.exec("INSERT OR REPLACE INTO tracked_files(filename) VALUES (?)", fileAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to track file: %v", err)
+	}
+
+	return nil
+}
+
+// UntrackFile removes the file from the tracked files along with its sync state
+func (s *SqliteMetadataStore) UntrackFile(fileAddress string) error {
+	err := s.exec("DELETE FROM tracked_files WHERE filename = ?", fileAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to untrack file: %v", err)
+	}
+
+	return s.Remove(fileAddress)
+}
+
+func (s *SqliteMetadataStore) GetTrackedFiles() ([]string, error) {
+	files, err := s.queryStrings("SELECT filename FROM tracked_files")
+
+	if err != nil {
+		return files, fmt.Errorf("failed to query for tracked files: %v", err)
+	}
+
+	return files, nil
+}
+
+func (s *SqliteMetadataStore) TrackDirectory(dirName string) error {
+	err := s.exec("INSERT OR REPLACE INTO tracked_dirs(dirname) VALUES (?)", dirName)
+
+	if err != nil {
+		return fmt.Errorf("failed to track directory: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SqliteMetadataStore) UntrackDirectory(dirName string) error {
+	err := s.exec("DELETE FROM tracked_dirs WHERE dirname = ?", dirName)
+
+	if err != nil {
+		return fmt.Errorf("failed to untrack directory: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SqliteMetadataStore) GetTrackedDirectories() ([]string, error) {
+	dirs, err := s.queryStrings("SELECT dirname FROM tracked_dirs")
+
+	if err != nil {
+		return dirs, fmt.Errorf("failed to query for tracked directories: %v", err)
+	}
+
+	return dirs, nil
+}
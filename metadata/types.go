@@ -5,6 +5,9 @@ import "time"
 type FileMetadata struct {
 	RemoteModDate time.Time
 	LocalModDate  time.Time
+	// Hash is the md5 of the content both sides had after the last sync
+	Hash string
+	Size int64
 }
 
 type Store interface {
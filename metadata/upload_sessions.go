@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// UploadSession is an upload the remote storage can resume, Hash and Size are the content it was started with.
+// SignatureId is the signature the upload refers to, resuming has to reuse it and purging has to keep it
+type UploadSession struct {
+	URI         string
+	Hash        string
+	Size        int64
+	Offset      int64
+	SignatureId string
+}
+
+// UploadSessionStore keeps unfinished uploads across runs so they resume where they stopped
+type UploadSessionStore interface {
+	GetUploadSession(key string) (bool, UploadSession, error)
+	SetUploadSession(key string, session UploadSession) error
+	RemoveUploadSession(key string) error
+}
+
+// UploadSessionKey identifies the upload of fileName into the remote folder parentId
+func UploadSessionKey(parentId string, fileName string) string {
+	return parentId + "/" + fileName
+}
+
+func (s *SqliteMetadataStore) GetUploadSession(key string) (bool, UploadSession, error) {
+	var session UploadSession
+
+	err := s.db.QueryRow(
+		"SELECT session_uri, hash, size, offset, signature_id FROM upload_sessions WHERE upload_key = ?", key).
+		Scan(&session.URI, &session.Hash, &session.Size, &session.Offset, &session.SignatureId)
+
+	if err == sql.ErrNoRows {
+		return false, UploadSession{}, nil
+	} else if err != nil {
+		return false, UploadSession{}, fmt.Errorf("failed to get upload session: %v", err)
+	}
+
+	return true, session, nil
+}
+
+func (s *SqliteMetadataStore) SetUploadSession(key string, session UploadSession) error {
+	_, err := s.db.Exec(
+		"INSERT OR REPLACE INTO upload_sessions(upload_key, session_uri, hash, size, offset, signature_id) VALUES (?, ?, ?, ?, ?, ?)",
+		key, session.URI, session.Hash, session.Size, session.Offset, session.SignatureId)
+
+	if err != nil {
+		return fmt.Errorf("failed to write upload session: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SqliteMetadataStore) RemoveUploadSession(key string) error {
+	err := s.exec("DELETE FROM upload_sessions WHERE upload_key = ?", key)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove upload session: %v", err)
+	}
+
+	return nil
+}
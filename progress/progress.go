@@ -0,0 +1,216 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BarInterval is how often the bar is redrawn on a terminal, LogInterval how often a line is logged otherwise.
+// Transfers finishing sooner aren't reported at all
+const BarInterval = 200 * time.Millisecond
+const LogInterval = 10 * time.Second
+
+const barWidth = 30
+
+// terminal is set when stderr, where the log goes too, is a terminal
+var terminal = isTerminal(os.Stderr)
+
+// drawMutex keeps the bars of concurrent transfers from mixing, they share the last line
+var drawMutex sync.Mutex
+
+func isTerminal(f *os.File) bool {
+	stats, err := f.Stat()
+
+	return err == nil && stats.Mode()&os.ModeCharDevice != 0
+}
+
+// Reader reports the progress of the transfer reading through it
+type Reader struct {
+	reader  io.Reader
+	name    string
+	size    int64
+	started time.Time
+
+	mutex    sync.Mutex
+	done     int64
+	reported time.Time
+	drawn    bool
+}
+
+// Transfer is a reader reporting its progress until Done
+type Transfer interface {
+	io.Reader
+	Done()
+}
+
+// seekReader is a Reader over a reader able to seek, so retries can start over
+type seekReader struct {
+	*Reader
+}
+
+// NewReader reports reading size bytes from reader as the transfer name, size is 0 when it's unknown.
+// The transfer is an io.Seeker only when reader is one
+func NewReader(reader io.Reader, name string, size int64) Transfer {
+	r := &Reader{reader: reader, name: name, size: size}
+
+	if _, ok := reader.(io.Seeker); ok {
+		return &seekReader{r}
+	}
+
+	return r
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+
+	// The transfer starts with its first read, time spent before, like hashing the content, isn't part of the rate
+	if r.started.IsZero() {
+		r.started = time.Now()
+		r.reported = r.started
+	}
+
+	r.mutex.Unlock()
+
+	n, err := r.reader.Read(p)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done += int64(n)
+	r.report(false)
+
+	return n, err
+}
+
+// Seek moves the transfer along with the reader, a retry starting over shows as such
+func (r *seekReader) Seek(offset int64, whence int) (int64, error) {
+	position, err := r.reader.(io.Seeker).Seek(offset, whence)
+
+	if err != nil {
+		return position, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done = position
+
+	return position, nil
+}
+
+// Unwrap returns the reader the transfer reads through, reading it directly isn't reported
+func (r *Reader) Unwrap() io.Reader {
+	return r.reader
+}
+
+// Done ends the report, the bar is left on its own line
+func (r *Reader) Done() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.report(true)
+}
+
+// report draws the bar or logs a line once the interval passed since the last one, the mutex must be held
+func (r *Reader) report(final bool) {
+	now := time.Now()
+	interval := LogInterval
+
+	if terminal {
+		interval = BarInterval
+	}
+
+	if final && !r.drawn {
+		return
+	}
+
+	if !final && now.Sub(r.reported) < interval {
+		return
+	}
+
+	r.reported = now
+	r.drawn = true
+
+	elapsed := now.Sub(r.started)
+	var rate float64
+
+	if elapsed > 0 {
+		rate = float64(r.done) / elapsed.Seconds()
+	}
+
+	status := fmt.Sprintf("%s/s", formatBytes(int64(rate)))
+
+	if final {
+		status = fmt.Sprintf("%s in %s, %s", formatBytes(r.done), elapsed.Round(time.Second), status)
+	} else if r.size > 0 {
+		status = fmt.Sprintf("%s of %s, %s", formatBytes(r.done), formatBytes(r.size), status)
+
+		if rate > 0 {
+			eta := time.Duration(float64(r.size-r.done) / rate * float64(time.Second))
+			status += fmt.Sprintf(", %s left", eta.Round(time.Second))
+		}
+	} else {
+		status = fmt.Sprintf("%s, %s", formatBytes(r.done), status)
+	}
+
+	if !terminal {
+		if final {
+			log.Printf("%s: done, %s", r.name, status)
+		} else if r.size > 0 {
+			log.Printf("%s: %d%%, %s", r.name, r.done*100/r.size, status)
+		} else {
+			log.Printf("%s: %s", r.name, status)
+		}
+
+		return
+	}
+
+	var bar = ""
+
+	if r.size > 0 {
+		filled := int(r.done * barWidth / r.size)
+
+		if filled > barWidth {
+			filled = barWidth
+		}
+
+		bar = fmt.Sprintf(" [%s%s] %3d%%", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), r.done*100/r.size)
+	}
+
+	var end = ""
+
+	if final {
+		end = "\n"
+	}
+
+	drawMutex.Lock()
+	defer drawMutex.Unlock()
+
+	// \r and clearing the line redraw in place
+	fmt.Fprintf(os.Stderr, "\r\033[K%s%s %s%s", r.name, bar, status, end)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n) / unit
+
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+
+		value /= unit
+	}
+
+	return fmt.Sprintf("%.1f TiB", value)
+}
@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSeekOnlyWhenReaderSeeks(t *testing.T) {
+	if _, ok := NewReader(ioutil.NopCloser(strings.NewReader("content")), "plain", 7).(io.Seeker); ok {
+		t.Errorf("expected a reader without Seek not to be seekable through the transfer")
+	}
+
+	transfer := NewReader(bytes.NewReader([]byte("content")), "seekable", 7)
+	seeker, ok := transfer.(io.ReadSeeker)
+
+	if !ok {
+		t.Fatalf("expected a seekable reader to stay seekable through the transfer")
+	}
+
+	_, err := ioutil.ReadAll(seeker)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	// A retry starts over
+	_, err = seeker.Seek(0, io.SeekStart)
+
+	if err != nil {
+		t.Fatalf("seek failed: %v", err)
+	}
+
+	content, err := ioutil.ReadAll(seeker)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if string(content) != "content" {
+		t.Errorf("expected the content to be read again, got %q", content)
+	}
+
+	if done := transfer.(*seekReader).done; done != 7 {
+		t.Errorf("expected the transfer to count 7 bytes after starting over, got %d", done)
+	}
+
+	transfer.Done()
+}
@@ -0,0 +1,47 @@
+package remote
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Wrapper is upload content reading through another reader, like a progress report
+type Wrapper interface {
+	Unwrap() io.Reader
+}
+
+// ContentInfo reads the content through from its position for its md5 and size, then rewinds it.
+// Wrapped content is hashed through the reader it wraps, hashing isn't part of the transfer
+func ContentInfo(content io.ReadSeeker) (start int64, size int64, hash string, err error) {
+	start, err = content.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to get content position: %v", err)
+	}
+
+	var source io.Reader = content
+
+	if wrapper, ok := content.(Wrapper); ok {
+		if inner, ok := wrapper.Unwrap().(io.ReadSeeker); ok {
+			source = inner
+		}
+	}
+
+	md5Hash := md5.New()
+	size, err = io.Copy(md5Hash, source)
+
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to hash content: %v", err)
+	}
+
+	// Rewinding the content itself moves the wrapper back along with the reader it wraps
+	_, err = content.Seek(start, io.SeekStart)
+
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to rewind content: %v", err)
+	}
+
+	return start, size, hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
@@ -0,0 +1,68 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// countingReader stands in for a progress report, counting what the transfer reads through it
+type countingReader struct {
+	reader *bytes.Reader
+	read   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+
+	return n, err
+}
+
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	return c.reader.Seek(offset, whence)
+}
+
+func (c *countingReader) Unwrap() io.Reader {
+	return c.reader
+}
+
+func TestContentInfoHashesWrappedReader(t *testing.T) {
+	data := []byte("skip this, hash the rest")
+	content := &countingReader{reader: bytes.NewReader(data)}
+
+	_, err := content.Seek(10, io.SeekStart)
+
+	if err != nil {
+		t.Fatalf("seek failed: %v", err)
+	}
+
+	start, size, hash, err := ContentInfo(content)
+
+	if err != nil {
+		t.Fatalf("content info failed: %v", err)
+	}
+
+	expected := md5.Sum(data[10:])
+
+	if start != 10 || size != int64(len(data)-10) || hash != hex.EncodeToString(expected[:]) {
+		t.Errorf("expected start 10, size %d and hash %x, got %d, %d and %s", len(data)-10, expected, start, size, hash)
+	}
+
+	if content.read != 0 {
+		t.Errorf("expected hashing to bypass the wrapper, %d bytes were read through it", content.read)
+	}
+
+	rest, err := ioutil.ReadAll(content)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if string(rest) != string(data[10:]) {
+		t.Errorf("expected the content to be rewound to its start, read %q", rest)
+	}
+}
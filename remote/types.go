@@ -0,0 +1,51 @@
+package remote
+
+import (
+	"io"
+	"time"
+)
+
+// Version is a single uploaded copy of a file in the remote storage
+type Version struct {
+	Id           string            `json:"id"`
+	Name         string            `json:"name"`
+	ModifiedTime time.Time         `json:"modifiedTime"`
+	Properties   map[string]string `json:"properties,omitempty"`
+	// Md5Checksum is the hex md5 of the content, empty when the backend can't provide it
+	Md5Checksum string `json:"md5Checksum,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+// Remote is a storage backend able to keep multiple versions of the same file name inside a folder
+type Remote interface {
+	// GetOrCreateDirectory returns the id of the named folder, an empty parentId means the storage root
+	GetOrCreateDirectory(parentId string, directoryName string) (string, error)
+	// FindDirectory returns the id of the named folder without creating it, exists is false when it's missing
+	FindDirectory(parentId string, directoryName string) (string, bool, error)
+	ListVersions(parentId string, fileName string) ([]*Version, error)
+	Upload(parentId string, fileName string, modTime time.Time, properties map[string]string, content io.Reader) (*Version, error)
+	Download(id string) (io.ReadCloser, error)
+	Delete(id string) error
+}
+
+// FolderLister is implemented by backends able to list a whole folder in fewer round trips than
+// listing each file in it, callers fall back to ListVersions otherwise
+type FolderLister interface {
+	// ListFolder returns the versions of every file directly inside parentId, folders excluded
+	ListFolder(parentId string) ([]*Version, error)
+}
+
+// Change is a file added or updated in the remote storage
+type Change struct {
+	Name      string
+	ParentIds []string
+}
+
+// ChangeLister is implemented by backends able to tell which files changed since a token,
+// so polling doesn't have to list every file
+type ChangeLister interface {
+	// StartChangeToken returns the token for changes made from now on
+	StartChangeToken() (string, error)
+	// ListChanges returns the changes made since token, and the token to pass next time
+	ListChanges(token string) ([]*Change, string, error)
+}
@@ -0,0 +1,46 @@
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// VersionsSuffix is appended to a file name to get the folder keeping its versions, for backends
+// storing every version as a separate object named <name>.versions/<timestamp>-<suffix>
+const VersionsSuffix = ".versions"
+
+// VersionsFolder returns the folder holding the versions of fileName inside parentId
+func VersionsFolder(parentId string, fileName string) string {
+	return path.Join(parentId, fileName+VersionsSuffix)
+}
+
+// VersionName returns the file name a version id created by NewVersionId belongs to
+func VersionName(id string) string {
+	return strings.TrimSuffix(path.Base(path.Dir(id)), VersionsSuffix)
+}
+
+// VersionTime truncates modTime to the second precision drive stores, the metadata store
+// compares modification times against it so every backend has to keep the same precision
+func VersionTime(modTime time.Time) time.Time {
+	return modTime.Truncate(time.Second)
+}
+
+// NewVersionId returns the id of a new version of fileName modified at modTime, the random suffix
+// keeps uploads of the same modification time apart
+func NewVersionId(parentId string, fileName string, modTime time.Time) (string, error) {
+	suffix := make([]byte, 4)
+
+	_, err := rand.Read(suffix)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to generate version id: %v", err)
+	}
+
+	name := VersionTime(modTime).UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	return path.Join(VersionsFolder(parentId, fileName), name), nil
+}
@@ -0,0 +1,92 @@
+package retry
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy repeats failed calls waiting exponentially longer each time, the delay doubles from BaseDelay up to MaxDelay
+type Policy struct {
+	// MaxRetries is how many times a failed call is repeated, 0 disables retries
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultPolicy = Policy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+// Classifier tells whether a failed call is worth repeating, and how long the server asked to wait before it, 0 if it didn't
+type Classifier func(err error) (retry bool, after time.Duration)
+
+// Delay returns the wait before the retry numbered attempt, counting from 0. The jitter spreads the retries
+// of calls that failed together, so they don't hit the server at the same time again
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+
+	for i := 0; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Do calls call until it succeeds, fails with an error classify doesn't retry, or the retries run out
+func (p Policy) Do(call func() error, classify Classifier) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+
+		if err == nil {
+			return nil
+		}
+
+		retry, after := classify(err)
+
+		if !retry {
+			return err
+		}
+
+		if attempt == p.MaxRetries {
+			if attempt == 0 {
+				return err
+			}
+
+			return fmt.Errorf("gave up after %d retries: %v", attempt, err)
+		}
+
+		delay := p.Delay(attempt)
+
+		if after > delay {
+			delay = after
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// ParseRetryAfter reads a Retry-After header, given either in seconds or as a date. It returns 0 without a usable value
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
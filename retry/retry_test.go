@@ -0,0 +1,62 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDelayBounds(t *testing.T) {
+	p := Policy{MaxRetries: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for i := 0; i < 100; i++ {
+			delay := p.Delay(attempt)
+
+			if delay < expected/2 || delay > expected {
+				t.Fatalf("attempt %d: expected a delay between %v and %v, got %v", attempt, expected/2, expected, delay)
+			}
+		}
+	}
+
+	if delay := (Policy{}).Delay(3); delay != 0 {
+		t.Errorf("expected no delay without a base delay, got %v", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]time.Duration{
+		"":     0,
+		"120":  2 * time.Minute,
+		"0":    0,
+		"-5":   0,
+		"soon": 0,
+		now.Add(90 * time.Second).Format(http.TimeFormat): 90 * time.Second,
+		now.Add(-time.Minute).Format(http.TimeFormat):     0}
+
+	for value, expected := range cases {
+		if after := ParseRetryAfter(value, now); after != expected {
+			t.Errorf("'%s': expected %v, got %v", value, expected, after)
+		}
+	}
+}
+
+func TestDoStopsOnPermanentErrors(t *testing.T) {
+	p := Policy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	permanent := errors.New("permanent")
+	calls := 0
+
+	err := p.Do(func() error {
+		calls++
+		return permanent
+	}, func(err error) (bool, time.Duration) {
+		return false, 0
+	})
+
+	if err != permanent || calls != 1 {
+		t.Errorf("expected a single call returning the error, got %d calls and %v", calls, err)
+	}
+}
@@ -0,0 +1,252 @@
+package s3remote
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/minio/minio-go"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const modifiedTimeKey = "Modified-Time"
+const md5ChecksumKey = "Md5-Checksum"
+const propertyPrefix = "Prop-"
+const userMetadataPrefix = "X-Amz-Meta-"
+
+// S3Remote keeps every version of a file as a separate object under <folder>/<name>.versions/<id>,
+// properties and the modified time are stored as object user metadata
+type S3Remote struct {
+	client *minio.Client
+	bucket string
+	// missing is set when the bucket doesn't exist and wasn't created, so every folder is missing
+	missing bool
+}
+
+// NewS3Remote connects to the bucket at endpoint, an http:// endpoint disables TLS.
+// A missing bucket is created when create is set, read only runs leave it missing instead
+func NewS3Remote(endpoint string, accessKey string, secretKey string, bucket string, create bool) (*S3Remote, error) {
+	endpointURL, err := url.Parse(endpoint)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint '%s': %v", endpoint, err)
+	}
+
+	if endpointURL.Host == "" {
+		// No scheme given, treat the whole value as host:port
+		endpointURL = &url.URL{Scheme: "https", Host: endpoint}
+	}
+
+	client, err := minio.New(endpointURL.Host, accessKey, secretKey, endpointURL.Scheme != "http")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+
+	exists, err := client.BucketExists(bucket)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %v", bucket, err)
+	}
+
+	if !exists && !create {
+		return &S3Remote{client: client, bucket: bucket, missing: true}, nil
+	}
+
+	if !exists {
+		err = client.MakeBucket(bucket, "")
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %v", bucket, err)
+		}
+	}
+
+	return &S3Remote{client: client, bucket: bucket}, nil
+}
+
+func (s *S3Remote) toVersion(info minio.ObjectInfo) (*remote.Version, error) {
+	properties := make(map[string]string)
+	var modifiedTime = ""
+	var checksum = ""
+
+	for key, values := range info.Metadata {
+		if len(values) == 0 {
+			continue
+		}
+
+		canonicalKey := strings.TrimPrefix(key, userMetadataPrefix)
+
+		if canonicalKey == key {
+			continue
+		} else if canonicalKey == modifiedTimeKey {
+			modifiedTime = values[0]
+		} else if canonicalKey == md5ChecksumKey {
+			checksum = values[0]
+		} else if strings.HasPrefix(canonicalKey, propertyPrefix) {
+			properties[strings.ToLower(strings.TrimPrefix(canonicalKey, propertyPrefix))] = values[0]
+		}
+	}
+
+	mTime := info.LastModified
+
+	if modifiedTime != "" {
+		var err error
+		mTime, err = time.Parse(time.RFC3339, modifiedTime)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse modified time '%s' of %s: %v", modifiedTime, info.Key, err)
+		}
+	}
+
+	etag := strings.Trim(info.ETag, "\"")
+
+	// The etag of a single part upload is the content md5, multipart etags contain a dash
+	if checksum == "" && !strings.Contains(etag, "-") {
+		checksum = etag
+	}
+
+	return &remote.Version{
+		Id:           info.Key,
+		Name:         remote.VersionName(info.Key),
+		ModifiedTime: mTime,
+		Properties:   properties,
+		Md5Checksum:  checksum,
+		Size:         info.Size}, nil
+}
+
+func (s *S3Remote) GetOrCreateDirectory(parentId string, directoryName string) (string, error) {
+	// Folders are only key prefixes, nothing has to be created
+	return path.Join(parentId, directoryName), nil
+}
+
+func (s *S3Remote) FindDirectory(parentId string, directoryName string) (string, bool, error) {
+	if s.missing {
+		return "", false, nil
+	}
+
+	// Every prefix exists, a missing folder just lists no versions
+	return path.Join(parentId, directoryName), true, nil
+}
+
+func (s *S3Remote) ListVersions(parentId string, fileName string) ([]*remote.Version, error) {
+	prefix := remote.VersionsFolder(parentId, fileName) + "/"
+	versions := make([]*remote.Version, 0)
+
+	if s.missing {
+		return versions, nil
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for object := range s.client.ListObjectsV2(s.bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("unable to retrieve files: %v", object.Err)
+		}
+
+		if strings.HasSuffix(object.Key, "/") {
+			// Folder placeholder objects created by other tools
+			continue
+		}
+
+		// Listing doesn't include user metadata, stat every version for it
+		info, err := s.client.StatObject(s.bucket, object.Key, minio.StatObjectOptions{})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %v", object.Key, err)
+		}
+
+		version, err := s.toVersion(info)
+
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (s *S3Remote) Upload(parentId string, fileName string, modTime time.Time, properties map[string]string, content io.Reader) (*remote.Version, error) {
+	modTime = remote.VersionTime(modTime)
+	key, err := remote.NewVersionId(parentId, fileName, modTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userMetadata := map[string]string{modifiedTimeKey: modTime.UTC().Format(time.RFC3339)}
+
+	for key, value := range properties {
+		userMetadata[propertyPrefix+key] = value
+	}
+
+	var size int64 = -1
+	var checksum = ""
+
+	// Metadata goes out before the content, so the checksum is only known up front for seekable content
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		_, size, checksum, err = remote.ContentInfo(seeker)
+
+		if err != nil {
+			return nil, err
+		}
+
+		userMetadata[md5ChecksumKey] = checksum
+	}
+
+	n, err := s.client.PutObject(s.bucket, key, content, size, minio.PutObjectOptions{UserMetadata: userMetadata})
+
+	if err != nil {
+		return nil, fmt.Errorf("upload operation failed: %v", err)
+	}
+
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+
+	return &remote.Version{
+		Id:           key,
+		Name:         fileName,
+		ModifiedTime: modTime,
+		Properties:   properties,
+		Md5Checksum:  checksum,
+		Size:         n}, nil
+}
+
+func (s *S3Remote) Download(id string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(s.bucket, id, minio.GetObjectOptions{})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %v", id, err)
+	}
+
+	// GetObject is lazy, stat to surface a missing object here rather than on the first read
+	_, err = object.Stat()
+
+	if err != nil {
+		closeErr := object.Close()
+
+		if closeErr != nil {
+			log.Printf("failed to close object: %v", closeErr)
+		}
+
+		return nil, fmt.Errorf("failed to download file %s: %v", id, err)
+	}
+
+	return object, nil
+}
+
+func (s *S3Remote) Delete(id string) error {
+	err := s.client.RemoveObject(s.bucket, id)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete file %s: %v", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package s3remote
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *minio.Client) {
+	server := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), "access", "secret", false)
+
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+
+	return server, client
+}
+
+func newTestRemote(t *testing.T) *S3Remote {
+	server, _ := newTestServer(t)
+
+	rmt, err := NewS3Remote(server.URL, "access", "secret", "bucket", true)
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	return rmt
+}
+
+func TestUploadRoundTripsMetadata(t *testing.T) {
+	rmt := newTestRemote(t)
+	content := []byte("some file content")
+	modTime := time.Date(2020, 5, 17, 10, 30, 15, 500, time.UTC)
+
+	properties := map[string]string{
+		"mode": "0644",
+		"gpg":  "folder/file.txt.sig.versions/20200517T103015Z-00000000",
+		"host": "laptop"}
+
+	uploaded, err := rmt.Upload("folder", "file.txt", modTime, properties, bytes.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	versions, err := rmt.ListVersions("folder", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(versions))
+	}
+
+	version := versions[0]
+	sum := md5.Sum(content)
+
+	if version.Id != uploaded.Id {
+		t.Errorf("expected id %s, got %s", uploaded.Id, version.Id)
+	}
+
+	if version.Name != "file.txt" {
+		t.Errorf("expected name file.txt, got %s", version.Name)
+	}
+
+	if !version.ModifiedTime.Equal(modTime.Truncate(time.Second)) {
+		t.Errorf("expected modified time %v, got %v", modTime.Truncate(time.Second), version.ModifiedTime)
+	}
+
+	if version.Md5Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected checksum %s, got %s", hex.EncodeToString(sum[:]), version.Md5Checksum)
+	}
+
+	if version.Size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), version.Size)
+	}
+
+	for key, value := range properties {
+		if version.Properties[key] != value {
+			t.Errorf("expected property %s=%s, got '%s'", key, value, version.Properties[key])
+		}
+	}
+
+	body, err := rmt.Download(version.Id)
+
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	defer body.Close()
+
+	downloaded, err := ioutil.ReadAll(body)
+
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("downloaded content doesn't match")
+	}
+}
+
+func TestMultipartETagIsNotAChecksum(t *testing.T) {
+	rmt := &S3Remote{}
+
+	info := minio.ObjectInfo{
+		Key:  "folder/file.txt.versions/20200517T103015Z-00000000",
+		ETag: "\"d41d8cd98f00b204e9800998ecf8427e-3\"",
+		Size: 3 * 5 * 1024 * 1024}
+
+	version, err := rmt.toVersion(info)
+
+	if err != nil {
+		t.Fatalf("failed to convert object: %v", err)
+	}
+
+	if version.Md5Checksum != "" {
+		t.Errorf("expected no checksum for a multipart etag, got %s", version.Md5Checksum)
+	}
+
+	info.ETag = "\"d41d8cd98f00b204e9800998ecf8427e\""
+
+	version, err = rmt.toVersion(info)
+
+	if err != nil {
+		t.Fatalf("failed to convert object: %v", err)
+	}
+
+	if version.Md5Checksum != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("expected the single part etag as checksum, got '%s'", version.Md5Checksum)
+	}
+}
+
+func TestReadOnlyMissingBucket(t *testing.T) {
+	server, client := newTestServer(t)
+
+	rmt, err := NewS3Remote(server.URL, "access", "secret", "bucket", false)
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	exists, err := client.BucketExists("bucket")
+
+	if err != nil {
+		t.Fatalf("failed to check bucket: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("expected a read only remote not to create the bucket")
+	}
+
+	_, exists, err = rmt.FindDirectory("", "sync")
+
+	if err != nil {
+		t.Fatalf("failed to find folder: %v", err)
+	}
+
+	if exists {
+		t.Errorf("expected the folder of a missing bucket to be missing")
+	}
+
+	versions, err := rmt.ListVersions("sync", "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 0 {
+		t.Errorf("expected no versions in a missing bucket, got %d", len(versions))
+	}
+}
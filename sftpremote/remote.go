@@ -0,0 +1,221 @@
+package sftpremote
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/localdir"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path"
+)
+
+const DefaultPort = "22"
+
+const posixRenameExtension = "posix-rename@openssh.com"
+
+// sftpFileSystem exposes an sftp session as the file tree used by the directory remote
+type sftpFileSystem struct {
+	client *sftp.Client
+}
+
+func (f sftpFileSystem) MkdirAll(name string) error {
+	return f.client.MkdirAll(name)
+}
+
+func (f sftpFileSystem) Create(name string) (io.WriteCloser, error) {
+	return f.client.Create(name)
+}
+
+func (f sftpFileSystem) Open(name string) (io.ReadCloser, error) {
+	return f.client.Open(name)
+}
+
+func (f sftpFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return f.client.ReadDir(name)
+}
+
+func (f sftpFileSystem) Remove(name string) error {
+	return f.client.Remove(name)
+}
+
+// Rename replaces newName like a local rename, plain sftp renames refuse an existing target so it's removed first
+// on servers without the posix-rename extension
+func (f sftpFileSystem) Rename(oldName, newName string) error {
+	if _, ok := f.client.HasExtension(posixRenameExtension); ok {
+		return f.client.PosixRename(oldName, newName)
+	}
+
+	err := f.client.Remove(newName)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return f.client.Rename(oldName, newName)
+}
+
+// SFTPRemote uses the same layout as the local directory backend, inside a directory on an ssh host
+type SFTPRemote struct {
+	*localdir.DirectoryRemote
+	sshClient  *ssh.Client
+	sftpClient *sftp.Client
+}
+
+func signerFromFile(keyPath string, passphrase []byte) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(keyPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read private key: %v", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("private key %s is protected by a passphrase, none was given", keyPath)
+		}
+
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %v", err)
+	}
+
+	return signer, nil
+}
+
+// authMethods offers the keys of a running ssh-agent, then the key file. The key file may be missing when an agent
+// is running, the agent connection is nil without one and is closed once connected
+func authMethods(keyPath string, passphrase []byte) ([]ssh.AuthMethod, net.Conn, error) {
+	methods := make([]ssh.AuthMethod, 0)
+	var agentConn net.Conn
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		var err error
+		agentConn, err = net.Dial("unix", socket)
+
+		if err != nil {
+			log.Printf("failed to connect to ssh-agent, only using %s: %v", keyPath, err)
+		} else {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) && agentConn != nil {
+		return methods, agentConn, nil
+	}
+
+	signer, err := signerFromFile(keyPath, passphrase)
+
+	if err != nil {
+		if agentConn != nil {
+			closeErr := agentConn.Close()
+
+			if closeErr != nil {
+				log.Printf("failed to close ssh-agent connection: %v", closeErr)
+			}
+		}
+
+		return nil, nil, err
+	}
+
+	methods = append(methods, ssh.PublicKeys(signer))
+
+	return methods, agentConn, nil
+}
+
+// NewSFTPRemote connects to host (host or host:port) as user, the host key must be in ~/.ssh/known_hosts.
+// It authenticates with ssh-agent when it's running and with keyPath, unlocked by passphrase when it's protected
+func NewSFTPRemote(host string, user string, keyPath string, passphrase []byte, remoteDir string) (*SFTPRemote, error) {
+	auth, agentConn, err := authMethods(keyPath, passphrase)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agentConn != nil {
+		defer func() {
+			err := agentConn.Close()
+
+			if err != nil {
+				log.Printf("failed to close ssh-agent connection: %v", err)
+			}
+		}()
+	}
+
+	hostKeyCallback, err := knownhosts.New(path.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %v", err)
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, DefaultPort)
+	}
+
+	sshClient, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", host, err)
+	}
+
+	sftpClient, err := sftp.NewClient(sshClient)
+
+	if err != nil {
+		closeErr := sshClient.Close()
+
+		if closeErr != nil {
+			log.Printf("failed to close ssh connection: %v", closeErr)
+		}
+
+		return nil, fmt.Errorf("failed to start sftp session: %v", err)
+	}
+
+	return NewClientRemote(sftpClient, sshClient, remoteDir)
+}
+
+// NewClientRemote wraps an established sftp session, sshClient may be nil when the session isn't over ssh
+func NewClientRemote(sftpClient *sftp.Client, sshClient *ssh.Client, remoteDir string) (*SFTPRemote, error) {
+	stat, err := sftpClient.Stat(remoteDir)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat remote sync directory: %v", err)
+	}
+
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("remote sync directory %s is not a directory", remoteDir)
+	}
+
+	return &SFTPRemote{
+		DirectoryRemote: localdir.NewFileSystemRemote(sftpFileSystem{client: sftpClient}, remoteDir),
+		sshClient:       sshClient,
+		sftpClient:      sftpClient}, nil
+}
+
+func (s *SFTPRemote) Close() error {
+	err := s.sftpClient.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to close sftp session: %v", err)
+	}
+
+	if s.sshClient != nil {
+		err = s.sshClient.Close()
+
+		if err != nil {
+			return fmt.Errorf("failed to close ssh connection: %v", err)
+		}
+	}
+
+	return nil
+}
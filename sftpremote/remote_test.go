@@ -0,0 +1,284 @@
+package sftpremote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noPosixRename serves plain renames only, like servers without the posix-rename extension
+type noPosixRename struct {
+	sftp.FileCmder
+	renames int
+}
+
+func (n *noPosixRename) Filecmd(r *sftp.Request) error {
+	if r.Method == "Rename" {
+		n.renames++
+	}
+
+	return n.FileCmder.Filecmd(r)
+}
+
+func (n *noPosixRename) PosixRename(r *sftp.Request) error {
+	return errors.New("posix-rename isn't supported")
+}
+
+// newTestRemote serves an in-memory file tree over a pipe, wrap lets a test change the file commands
+func newTestRemote(t *testing.T, wrap func(sftp.FileCmder) sftp.FileCmder) (*SFTPRemote, *sftp.Client) {
+	serverConn, clientConn := net.Pipe()
+	handlers := sftp.InMemHandler()
+
+	if wrap != nil {
+		handlers.FileCmd = wrap(handlers.FileCmd)
+	}
+
+	server := sftp.NewRequestServer(serverConn, handlers)
+	served := make(chan error, 1)
+
+	go func() {
+		served <- server.Serve()
+	}()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+
+	if err != nil {
+		t.Fatalf("failed to start sftp session: %v", err)
+	}
+
+	err = client.Mkdir("/sync")
+
+	if err != nil {
+		t.Fatalf("failed to create sync directory: %v", err)
+	}
+
+	rmt, err := NewClientRemote(client, nil, "/sync")
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err := rmt.Close()
+
+		if err != nil {
+			t.Errorf("failed to close remote: %v", err)
+		}
+
+		// The server ends with the session
+		err = <-served
+
+		if err != nil && err != io.EOF {
+			t.Errorf("sftp server failed: %v", err)
+		}
+	})
+
+	return rmt, client
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	rmt, client := newTestRemote(t, nil)
+	content := []byte("some file content")
+	modTime := time.Date(2020, 5, 17, 10, 30, 15, 0, time.UTC)
+
+	folderId, err := rmt.GetOrCreateDirectory("", "folder")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	uploaded, err := rmt.Upload(folderId, "file.txt", modTime, map[string]string{"host": "laptop"}, bytes.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if _, err := client.Stat(path.Join("/sync", uploaded.Id)); err != nil {
+		t.Errorf("expected the version inside the sync directory: %v", err)
+	}
+
+	versions, err := rmt.ListVersions(folderId, "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 1 || versions[0].Id != uploaded.Id || versions[0].Properties["host"] != "laptop" {
+		t.Fatalf("expected version %s to be listed with its properties, got %d versions", uploaded.Id, len(versions))
+	}
+
+	if !versions[0].ModifiedTime.Equal(modTime) || versions[0].Size != int64(len(content)) {
+		t.Errorf("expected modified time %v and size %d, got %v and %d", modTime, len(content), versions[0].ModifiedTime, versions[0].Size)
+	}
+
+	body, err := rmt.Download(uploaded.Id)
+
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	downloaded, err := ioutil.ReadAll(body)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	err = body.Close()
+
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("expected %q, got %q", content, downloaded)
+	}
+
+	err = rmt.Delete(uploaded.Id)
+
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	versions, err = rmt.ListVersions(folderId, "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 0 {
+		t.Errorf("expected no versions after delete, got %d", len(versions))
+	}
+}
+
+func TestRenameWithoutPosixRename(t *testing.T) {
+	err := sftp.SetSFTPExtensions("statvfs@openssh.com")
+
+	if err != nil {
+		t.Fatalf("failed to disable posix-rename: %v", err)
+	}
+
+	defer func() {
+		err := sftp.SetSFTPExtensions("hardlink@openssh.com", posixRenameExtension, "statvfs@openssh.com")
+
+		if err != nil {
+			t.Errorf("failed to restore extensions: %v", err)
+		}
+	}()
+
+	cmder := &noPosixRename{}
+	rmt, client := newTestRemote(t, func(fileCmd sftp.FileCmder) sftp.FileCmder {
+		cmder.FileCmder = fileCmd
+		return cmder
+	})
+
+	_, err = rmt.Upload("", "file.txt", time.Now(), nil, bytes.NewReader([]byte("content")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if cmder.renames == 0 {
+		t.Errorf("expected the upload to fall back on a plain rename")
+	}
+
+	fs := sftpFileSystem{client: client}
+
+	for name, content := range map[string]string{"/sync/old": "new content", "/sync/target": "old content"} {
+		fh, err := client.Create(name)
+
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+
+		_, err = fh.Write([]byte(content))
+
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+
+		err = fh.Close()
+
+		if err != nil {
+			t.Fatalf("failed to close %s: %v", name, err)
+		}
+	}
+
+	err = fs.Rename("/sync/old", "/sync/target")
+
+	if err != nil {
+		t.Fatalf("rename over an existing file failed: %v", err)
+	}
+
+	fh, err := client.Open("/sync/target")
+
+	if err != nil {
+		t.Fatalf("failed to open target: %v", err)
+	}
+
+	defer fh.Close()
+
+	renamed, err := ioutil.ReadAll(fh)
+
+	if err != nil {
+		t.Fatalf("failed to read target: %v", err)
+	}
+
+	if string(renamed) != "new content" {
+		t.Errorf("expected the target to be replaced, got %q", renamed)
+	}
+}
+
+func TestProtectedKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	keyPath := path.Join(t.TempDir(), "id_ed25519")
+
+	err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(block), 0600)
+
+	if err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	_, err = signerFromFile(keyPath, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "none was given") {
+		t.Errorf("expected a missing passphrase to fail, got %v", err)
+	}
+
+	_, err = signerFromFile(keyPath, []byte("wrong"))
+
+	if err == nil {
+		t.Errorf("expected a wrong passphrase to fail")
+	}
+
+	signer, err := signerFromFile(keyPath, []byte("secret"))
+
+	if err != nil {
+		t.Fatalf("failed to unlock key: %v", err)
+	}
+
+	if signer.PublicKey().Type() != ssh.KeyAlgoED25519 {
+		t.Errorf("expected an ed25519 key, got %s", signer.PublicKey().Type())
+	}
+}
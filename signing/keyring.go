@@ -0,0 +1,213 @@
+package signing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+const armorHeader = "-----BEGIN PGP"
+
+// Keyring signs and verifies detached OpenPGP signatures, the same binary .sig files gpg2 --detach-sig writes.
+// Ed25519 keys, the gpg default, are supported along with RSA
+type Keyring struct {
+	entities openpgp.EntityList
+	// signer is nil when the keyring only verifies
+	signer *openpgp.Entity
+}
+
+// readKeys reads a keyring exported by gpg --export or gpg --export-secret-keys, armored or not
+func readKeys(reader io.Reader) (openpgp.EntityList, error) {
+	buffered := bufio.NewReader(reader)
+	start, err := buffered.Peek(len(armorHeader))
+
+	if err == nil && string(start) == armorHeader {
+		return openpgp.ReadArmoredKeyRing(buffered)
+	}
+
+	return openpgp.ReadKeyRing(buffered)
+}
+
+// minKeyIdDigits is the length of a long key id, shorter ids are too easy to forge a key for
+const minKeyIdDigits = 16
+
+// parseKeyId normalizes a fingerprint or key id, ok is false when signKey isn't hex and so names a user id
+func parseKeyId(signKey string) (string, bool) {
+	keyId := strings.ToUpper(strings.Replace(signKey, " ", "", -1))
+	keyId = strings.TrimPrefix(keyId, "0X")
+
+	if keyId == "" {
+		return "", false
+	}
+
+	for _, c := range keyId {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return "", false
+		}
+	}
+
+	return keyId, true
+}
+
+// matchesKey tells if signKey is the fingerprint or long key id of the entity or one of its subkeys,
+// or one of its user ids or emails, like gpg2 --sign-with takes it
+func matchesKey(entity *openpgp.Entity, signKey string) bool {
+	if keyId, ok := parseKeyId(signKey); ok {
+		keys := []*packet.PublicKey{entity.PrimaryKey}
+
+		for _, subkey := range entity.Subkeys {
+			keys = append(keys, subkey.PublicKey)
+		}
+
+		for _, key := range keys {
+			if strings.HasSuffix(fmt.Sprintf("%X", key.Fingerprint), keyId) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	email := strings.TrimSuffix(strings.TrimPrefix(signKey, "<"), ">")
+
+	for name, identity := range entity.Identities {
+		if name == signKey || (identity.UserId != nil && identity.UserId.Email != "" && strings.EqualFold(identity.UserId.Email, email)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decrypt unlocks the private keys of the entity protected by a passphrase
+func decrypt(entity *openpgp.Entity, passphrase []byte) error {
+	keys := []*packet.PrivateKey{entity.PrivateKey}
+
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil {
+			keys = append(keys, subkey.PrivateKey)
+		}
+	}
+
+	for _, key := range keys {
+		if !key.Encrypted {
+			continue
+		}
+
+		if len(passphrase) == 0 {
+			return errors.New("the signing key is protected by a passphrase, none was given")
+		}
+
+		err := key.Decrypt(passphrase)
+
+		if err != nil {
+			return fmt.Errorf("failed to unlock the signing key: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// NewKeyring loads the keys of keyringFile, signKey picks the key signing uploads, empty only verifies.
+// It's a fingerprint, a long key id, a user id or an email. The passphrase unlocks the signing key when it's protected
+func NewKeyring(keyringFile string, signKey string, passphrase []byte) (*Keyring, error) {
+	fh, err := os.Open(keyringFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close keyring: %v", err)
+		}
+	}()
+
+	entities, err := readKeys(fh)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring %s: %v", keyringFile, err)
+	}
+
+	keyring := &Keyring{entities: entities}
+
+	if signKey == "" {
+		return keyring, nil
+	}
+
+	if keyId, ok := parseKeyId(signKey); ok && len(keyId) < minKeyIdDigits {
+		return nil, fmt.Errorf("key id %s is too short, give at least its %d digit long key id", signKey, minKeyIdDigits)
+	}
+
+	for _, entity := range entities {
+		if !matchesKey(entity, signKey) {
+			continue
+		}
+
+		if keyring.signer != nil {
+			return nil, fmt.Errorf("%s matches several keys in keyring %s, give the fingerprint of one", signKey, keyringFile)
+		}
+
+		keyring.signer = entity
+	}
+
+	if keyring.signer == nil {
+		return nil, fmt.Errorf("no key %s in keyring %s", signKey, keyringFile)
+	}
+
+	if keyring.signer.PrivateKey == nil {
+		return nil, fmt.Errorf("keyring %s has no secret key for %s", keyringFile, signKey)
+	}
+
+	err = decrypt(keyring.signer, passphrase)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// CanSign is set when the keyring has a signing key
+func (k *Keyring) CanSign() bool {
+	return k != nil && k.signer != nil
+}
+
+// Sign writes the binary detached signature of content
+func (k *Keyring) Sign(signature io.Writer, content io.Reader) error {
+	if !k.CanSign() {
+		return errors.New("no signing key configured")
+	}
+
+	return openpgp.DetachSign(signature, k.signer, content, nil)
+}
+
+// Verify checks content against its detached signature, binary or armored, made by any key of the keyring
+func (k *Keyring) Verify(content io.Reader, signature io.Reader) error {
+	if k == nil {
+		return errors.New("no keyring configured to verify signatures")
+	}
+
+	buffered := bufio.NewReader(signature)
+	start, err := buffered.Peek(len(armorHeader))
+
+	if err == nil && string(start) == armorHeader {
+		_, err = openpgp.CheckArmoredDetachedSignature(k.entities, content, buffered, nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(k.entities, content, buffered, nil)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package signing
+
+import (
+	"bytes"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ilyail3/fileSync/signing/signingtest"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	keyringFile, fingerprint := signingtest.WriteKeyring(t, nil)
+
+	keyring, err := NewKeyring(keyringFile, fingerprint, nil)
+
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+
+	if !keyring.CanSign() {
+		t.Fatalf("expected the keyring to sign")
+	}
+
+	content := "signed content"
+	var signature bytes.Buffer
+
+	err = keyring.Sign(&signature, strings.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// Verifying only needs the public key, a keyring without a signing key does
+	verifier, err := NewKeyring(keyringFile, "", nil)
+
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+
+	if verifier.CanSign() {
+		t.Errorf("expected a keyring without a signing key not to sign")
+	}
+
+	err = verifier.Verify(strings.NewReader(content), bytes.NewReader(signature.Bytes()))
+
+	if err != nil {
+		t.Errorf("expected the binary signature to verify: %v", err)
+	}
+
+	var armored bytes.Buffer
+	writer, err := armor.Encode(&armored, "PGP SIGNATURE", nil)
+
+	if err != nil {
+		t.Fatalf("failed to armor signature: %v", err)
+	}
+
+	_, err = writer.Write(signature.Bytes())
+
+	if err != nil {
+		t.Fatalf("failed to armor signature: %v", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatalf("failed to armor signature: %v", err)
+	}
+
+	err = verifier.Verify(strings.NewReader(content), &armored)
+
+	if err != nil {
+		t.Errorf("expected the armored signature to verify: %v", err)
+	}
+
+	err = verifier.Verify(strings.NewReader("tampered content"), bytes.NewReader(signature.Bytes()))
+
+	if err == nil {
+		t.Errorf("expected the signature of different content to fail")
+	}
+
+	otherFile, _ := signingtest.WriteKeyring(t, nil)
+	other, err := NewKeyring(otherFile, "", nil)
+
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+
+	err = other.Verify(strings.NewReader(content), bytes.NewReader(signature.Bytes()))
+
+	if err == nil {
+		t.Errorf("expected the signature of an unknown key to fail")
+	}
+}
+
+func TestProtectedKey(t *testing.T) {
+	passphrase := []byte("correct horse")
+	keyringFile, fingerprint := signingtest.WriteKeyring(t, passphrase)
+
+	_, err := NewKeyring(keyringFile, fingerprint, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "none was given") {
+		t.Errorf("expected a missing passphrase to fail, got %v", err)
+	}
+
+	_, err = NewKeyring(keyringFile, fingerprint, []byte("wrong"))
+
+	if err == nil || !strings.Contains(err.Error(), "failed to unlock") {
+		t.Errorf("expected a wrong passphrase to fail, got %v", err)
+	}
+
+	// The key id, the fingerprint suffix, picks the key too
+	keyring, err := NewKeyring(keyringFile, fingerprint[len(fingerprint)-16:], passphrase)
+
+	if err != nil {
+		t.Fatalf("failed to unlock keyring: %v", err)
+	}
+
+	var signature bytes.Buffer
+
+	err = keyring.Sign(&signature, strings.NewReader("content"))
+
+	if err != nil {
+		t.Fatalf("failed to sign with the unlocked key: %v", err)
+	}
+
+	err = keyring.Verify(strings.NewReader("content"), &signature)
+
+	if err != nil {
+		t.Errorf("expected the signature to verify: %v", err)
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	keyringFile, _ := signingtest.WriteKeyring(t, nil)
+
+	_, err := NewKeyring(keyringFile, "0123456789ABCDEF", nil)
+
+	if err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Errorf("expected an unknown signing key to fail, got %v", err)
+	}
+}
+
+func TestSignKeySelectors(t *testing.T) {
+	keyringFile, fingerprint := signingtest.WriteKeyring(t, nil)
+
+	for _, signKey := range []string{
+		fingerprint,
+		"0x" + strings.ToLower(fingerprint[len(fingerprint)-16:]),
+		fingerprint[len(fingerprint)-20:],
+		signingtest.Name + " <" + signingtest.Email + ">",
+		signingtest.Email,
+		"<" + strings.ToUpper(signingtest.Email) + ">",
+	} {
+		keyring, err := NewKeyring(keyringFile, signKey, nil)
+
+		if err != nil {
+			t.Errorf("%s: %v", signKey, err)
+			continue
+		}
+
+		if !keyring.CanSign() {
+			t.Errorf("%s: expected the keyring to sign", signKey)
+		}
+	}
+
+	// Short key ids are too easy to forge a key for
+	for _, signKey := range []string{fingerprint[len(fingerprint)-8:], "0x" + fingerprint[len(fingerprint)-15:]} {
+		_, err := NewKeyring(keyringFile, signKey, nil)
+
+		if err == nil || !strings.Contains(err.Error(), "too short") {
+			t.Errorf("%s: expected a short key id to be refused, got %v", signKey, err)
+		}
+	}
+
+	for _, signKey := range []string{signingtest.Name, "other@example.com"} {
+		_, err := NewKeyring(keyringFile, signKey, nil)
+
+		if err == nil || !strings.Contains(err.Error(), "no key") {
+			t.Errorf("%s: expected a partial user id not to match, got %v", signKey, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package signingtest generates keyrings for the tests of packages signing and verifying uploads
+package signingtest
+
+import (
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"os"
+	"path"
+	"testing"
+)
+
+// Name and Email make up the user id of the generated keys
+const Name = "sync test"
+const Email = "sync@example.com"
+
+// WriteKeyring generates an ed25519 key and writes it to a keyring file, protected when passphrase isn't empty.
+// It returns the file and the fingerprint of the key
+func WriteKeyring(t testing.TB, passphrase []byte) (string, string) {
+	entity, err := openpgp.NewEntity(Name, "", Email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	keyringFile := path.Join(t.TempDir(), "keyring.gpg")
+	fh, err := os.Create(keyringFile)
+
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	if len(passphrase) > 0 {
+		err = entity.EncryptPrivateKeys(passphrase, nil)
+
+		if err != nil {
+			t.Fatalf("failed to protect key: %v", err)
+		}
+
+		// The self signatures were made when generating it, the protected key can't sign them again
+		err = entity.SerializePrivateWithoutSigning(fh, nil)
+	} else {
+		err = entity.SerializePrivate(fh, nil)
+	}
+
+	if err != nil {
+		t.Fatalf("failed to write keyring: %v", err)
+	}
+
+	err = fh.Close()
+
+	if err != nil {
+		t.Fatalf("failed to close keyring: %v", err)
+	}
+
+	return keyringFile, fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
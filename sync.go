@@ -1,19 +1,76 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/emirpasic/gods/sets/hashset"
+	"github.com/ilyail3/fileSync/cleanup"
 	"github.com/ilyail3/fileSync/gdrive"
+	"github.com/ilyail3/fileSync/ignore"
+	"github.com/ilyail3/fileSync/localdir"
 	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/retry"
+	"github.com/ilyail3/fileSync/s3remote"
+	"github.com/ilyail3/fileSync/sftpremote"
+	"github.com/ilyail3/fileSync/signing"
+	"github.com/ilyail3/fileSync/syncer"
+	"github.com/ilyail3/fileSync/watch"
+	"github.com/ilyail3/fileSync/webdav"
 	"github.com/kardianos/osext"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"io/ioutil"
 
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 const DefaultSignKey = ""
+
+// PassphraseVariable names the environment variable holding the passphrase of the signing key
+const PassphraseVariable = "SYNC_SIGN_PASSPHRASE"
+
+// S3SecretKeyVariable names the environment variable holding the s3 secret access key
+const S3SecretKeyVariable = "SYNC_S3_SECRET_KEY"
+
+// WebDAVPasswordVariable names the environment variable holding the webdav password
+const WebDAVPasswordVariable = "SYNC_WEBDAV_PASSWORD"
+
+// SFTPPassphraseVariable names the environment variable holding the passphrase of the sftp private key
+const SFTPPassphraseVariable = "SYNC_SFTP_PASSPHRASE"
 const DefaultSyncFolderName = "sync"
+const DefaultBackend = "drive"
+
+type remoteFlags struct {
+	backend        *string
+	localDir       *string
+	s3Endpoint     *string
+	s3Bucket       *string
+	s3AccessKey    *string
+	s3SecretKey    *string
+	webdavURL      *string
+	webdavUser     *string
+	webdavPassword *string
+	sftpHost       *string
+	sftpUser       *string
+	sftpKey        *string
+	sftpDir        *string
+	retries        *int
+	retryDelay     *time.Duration
+	chunkSize      *int64
+}
 
 func getConfigOrDefault(db metadata.ConfigStore, keyName string, flag *string, defaultValue string) (string, error) {
 	if *flag != "" {
@@ -39,92 +96,1548 @@ func getConfigOrDefault(db metadata.ConfigStore, keyName string, flag *string, d
 	}
 }
 
-func main() {
-	execPath, err := osext.Executable()
+type backendSetting struct {
+	key      string
+	flag     *string
+	required bool
+	// variable is set for secrets, they are read from the flag or that environment variable and never stored
+	variable string
+}
+
+// readSecret reads a secret setting, falling back on a value stored by older versions
+func readSecret(db metadata.ConfigStore, setting backendSetting) (string, error) {
+	if *setting.flag != "" {
+		return *setting.flag, nil
+	}
+
+	if value := os.Getenv(setting.variable); value != "" {
+		return value, nil
+	}
+
+	exists, value, err := db.ReadStringConfig(setting.key)
 
 	if err != nil {
-		log.Fatalf("failed to get executable path")
+		return "", fmt.Errorf("error reading value: %v", err)
 	}
 
-	dirName := path.Dir(execPath)
-	// log.Printf("dir is:%s", dirName)
+	if exists && value != "" {
+		log.Printf("%s is stored in plain text in the metadata store, set $%s instead", setting.key, setting.variable)
+	}
 
-	dbDir := path.Join(os.Getenv("HOME"), ".bin")
-	mtStore, err := metadata.NewSQLite3Store(dbDir)
+	return value, nil
+}
+
+func readBackendConfig(db metadata.ConfigStore, backend string, settings []backendSetting) (map[string]string, error) {
+	values := make(map[string]string)
+
+	for _, setting := range settings {
+		var value string
+		var err error
+
+		if setting.variable != "" {
+			value, err = readSecret(db, setting)
+		} else {
+			value, err = getConfigOrDefault(db, setting.key, setting.flag, "")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get or write %s: %v", setting.key, err)
+		}
+
+		if setting.required && value == "" && setting.variable != "" {
+			return nil, fmt.Errorf("%s backend requires -%s or $%s", backend, setting.key, setting.variable)
+		} else if setting.required && value == "" {
+			return nil, fmt.Errorf("%s backend requires -%s", backend, setting.key)
+		}
+
+		values[setting.key] = value
+	}
+
+	return values, nil
+}
+
+// newRemote connects to the configured backend, readOnly runs leave missing storage uncreated
+func newRemote(db *metadata.SqliteMetadataStore, config metadata.ConfigStore, dirName string, flags remoteFlags, readOnly bool) (remote.Remote, error) {
+	backend, err := getConfigOrDefault(config, "backend", flags.backend, DefaultBackend)
 
 	if err != nil {
-		log.Fatalf("Failed to open metadata store: %v", err)
+		return nil, fmt.Errorf("failed to get or write backend: %v", err)
 	}
 
-	defer func() {
-		err := mtStore.Close()
+	switch backend {
+	case "drive":
+		client, err := gdrive.NewClient(dirName)
 
 		if err != nil {
-			log.Printf("failed to close metastore: %v", err)
+			return nil, fmt.Errorf("failed to inialize google drive service: %v", err)
 		}
-	}()
 
-	signKeyFlag := flag.String("sign-key", "", "sign key gpg2 signature")
-	folderNameFlag := flag.String("folder-name", "", "folder name for sync")
+		srv, err := drive.New(client)
 
-	flag.Parse()
-	args := flag.Args()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inialize google drive service: %v", err)
+		}
+
+		retryPolicy := retry.DefaultPolicy
+		retryPolicy.MaxRetries = *flags.retries
+		retryPolicy.BaseDelay = *flags.retryDelay
+
+		var uploads *gdrive.ResumableUploads
+
+		if *flags.chunkSize > 0 {
+			uploads, err = gdrive.NewResumableUploads(client, srv, db, *flags.chunkSize)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid -chunk-size: %v", err)
+			}
+		}
+
+		return gdrive.NewDriveRemote(srv, retryPolicy, uploads), nil
+	case "local":
+		values, err := readBackendConfig(config, "local", []backendSetting{
+			{"local-dir", flags.localDir, true, ""}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return localdir.NewDirectoryRemote(values["local-dir"])
+	case "s3":
+		values, err := readBackendConfig(config, "s3", []backendSetting{
+			{"s3-endpoint", flags.s3Endpoint, true, ""},
+			{"s3-bucket", flags.s3Bucket, true, ""},
+			{"s3-access-key", flags.s3AccessKey, true, ""},
+			{"s3-secret-key", flags.s3SecretKey, true, S3SecretKeyVariable}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return s3remote.NewS3Remote(
+			values["s3-endpoint"],
+			values["s3-access-key"],
+			values["s3-secret-key"],
+			values["s3-bucket"],
+			!readOnly)
+	case "webdav":
+		values, err := readBackendConfig(config, "webdav", []backendSetting{
+			{"webdav-url", flags.webdavURL, true, ""},
+			{"webdav-user", flags.webdavUser, false, ""},
+			{"webdav-password", flags.webdavPassword, false, WebDAVPasswordVariable}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return webdav.NewWebDAVRemote(
+			http.DefaultClient,
+			values["webdav-url"],
+			values["webdav-user"],
+			values["webdav-password"])
+	case "sftp":
+		values, err := readBackendConfig(config, "sftp", []backendSetting{
+			{"sftp-host", flags.sftpHost, true, ""},
+			{"sftp-user", flags.sftpUser, true, ""},
+			{"sftp-key", flags.sftpKey, false, ""},
+			{"sftp-dir", flags.sftpDir, true, ""}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		keyPath := values["sftp-key"]
+
+		if keyPath == "" {
+			keyPath = path.Join(os.Getenv("HOME"), ".ssh", "id_rsa")
+		}
 
-	srv, err := gdrive.NewService(dirName)
+		return sftpremote.NewSFTPRemote(
+			values["sftp-host"],
+			values["sftp-user"],
+			keyPath,
+			[]byte(os.Getenv(SFTPPassphraseVariable)),
+			values["sftp-dir"])
+	default:
+		return nil, fmt.Errorf("unknown backend '%s'", backend)
+	}
+}
+
+// trackedFiles merges the synced files with the current content of the tracked directories.
+// Files inside a tracked directory that a .syncignore now excludes are left out, deleted ones too
+// so they aren't downloaded again, with forget set their sync state is dropped as well
+func trackedFiles(mtStore *metadata.SqliteMetadataStore, forget bool) ([]string, error) {
+	synced, err := mtStore.GetAllSyncedFiles()
 
 	if err != nil {
-		log.Fatalf("Failed to inialize google drive service: %v", err)
+		return nil, err
 	}
 
-	folderName, err := getConfigOrDefault(
-		mtStore,
-		"folder-name",
-		folderNameFlag,
-		DefaultSyncFolderName)
+	tracked, err := mtStore.GetTrackedFiles()
 
 	if err != nil {
-		log.Fatalf("failed to get or write folder name: %v", err)
+		return nil, err
 	}
 
-	parentId, err := gdrive.GetOrCreateDirectory(srv, folderName)
+	dirs, err := mtStore.GetTrackedDirectories()
 
 	if err != nil {
-		log.Fatalf("failed to get parent directory: %v", err)
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	seen := hashset.New()
+
+	for _, dirName := range dirs {
+		dirFiles, err := syncer.ListDirectoryFiles(dirName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fullAddress := range dirFiles {
+			if !seen.Contains(fullAddress) {
+				seen.Add(fullAddress)
+				files = append(files, fullAddress)
+			}
+		}
+	}
+
+	for _, fullAddress := range append(tracked, synced...) {
+		if seen.Contains(fullAddress) {
+			continue
+		}
+
+		seen.Add(fullAddress)
+
+		excluded, err := excludedFile(fullAddress, dirs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !excluded {
+			// A missing file is still synced so it gets downloaded again
+			files = append(files, fullAddress)
+			continue
+		}
+
+		if !forget {
+			log.Printf("skipping %s, excluded by %s", fullAddress, ignore.FileName)
+			continue
+		}
+
+		log.Printf("forgetting %s, excluded by %s", fullAddress, ignore.FileName)
+
+		err = mtStore.UntrackFile(fullAddress)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// excludedFile reports if a .syncignore of the tracked directory holding fullAddress excludes it
+func excludedFile(fullAddress string, dirs []string) (bool, error) {
+	for _, dirName := range dirs {
+		if !insideDirectory(fullAddress, []string{dirName}) {
+			continue
+		}
+
+		excluded, err := ignore.Excluded(dirName, fullAddress)
+
+		if err != nil {
+			return false, fmt.Errorf("failed to read ignore files of %s: %v", dirName, err)
+		}
+
+		if excluded {
+			return true, nil
+		}
 	}
 
-	signKey, err := getConfigOrDefault(
-		mtStore,
-		"sign-key",
-		signKeyFlag,
-		DefaultSignKey)
+	return false, nil
+}
+
+// readRemoteLayout follows the layout recorded in the sync folder, so machines that didn't migrate use the same one.
+// A folder without a record gets the flag, the stored choice, or flat for stores that already synced files with it
+func (a *app) readRemoteLayout(parentId string) (string, bool, error) {
+	layoutFlag := *a.flags.remoteLayout
+
+	if *a.flags.migrateLayout {
+		if layoutFlag == syncer.FlatLayout {
+			return "", false, fmt.Errorf("-migrate-layout moves files to the %s layout", syncer.TreeLayout)
+		}
+
+		layoutFlag = syncer.TreeLayout
+	}
+
+	if layoutFlag != "" && layoutFlag != syncer.FlatLayout && layoutFlag != syncer.TreeLayout {
+		return "", false, fmt.Errorf("unknown remote layout '%s'", layoutFlag)
+	}
+
+	exists, layoutName, err := a.config.ReadStringConfig("remote-layout")
 
 	if err != nil {
-		log.Fatalf("failed to get or write signing key: %v", err)
+		return "", false, err
 	}
 
-	if len(args) == 0 {
-		files, err := mtStore.GetAllSyncedFiles()
+	remoteName, found := "", false
+
+	if !a.folderMissing {
+		remoteName, found, err = syncer.ReadLayout(a.rmt, parentId)
 
 		if err != nil {
-			log.Fatalf("failed to read all synced filenames: %v", err)
+			return "", false, err
 		}
+	}
 
-		for _, fullAddress := range files {
-			log.Printf("syncing file: %s", fullAddress)
+	switch {
+	case *a.flags.migrateLayout:
+		layoutName = syncer.TreeLayout
+	case found && layoutFlag != "" && layoutFlag != remoteName:
+		if remoteName == syncer.FlatLayout {
+			return "", false, fmt.Errorf("the sync folder uses the %s layout, use -migrate-layout to move it to the %s layout", remoteName, layoutFlag)
+		}
 
-			err = gdrive.SyncFile(fullAddress, parentId, srv, mtStore, signKey)
+		return "", false, fmt.Errorf("the sync folder uses the %s layout, it can't go back to the %s layout", remoteName, layoutFlag)
+	case found:
+		if exists && layoutName != remoteName {
+			log.Printf("following the %s layout of the sync folder instead of the %s layout", remoteName, layoutName)
+		}
 
-			if err != nil {
-				log.Fatalf("failed to sync filename %s: %v", fullAddress, err)
-			}
+		layoutName = remoteName
+	case layoutFlag != "":
+		layoutName = layoutFlag
+	case !exists:
+		synced, err := a.mtStore.GetAllSyncedFiles()
+
+		if err != nil {
+			return "", false, err
+		}
+
+		layoutName = syncer.TreeLayout
+
+		if len(synced) > 0 {
+			layoutName = syncer.FlatLayout
+		}
+	}
+
+	err = a.config.WriteStringConfig("remote-layout", layoutName)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return layoutName, found, nil
+}
+
+// readPlan loads a plan saved from -plan, grouping the actions by file in their original order
+func readPlan(address string) ([]string, map[string][]*syncer.Action, error) {
+	content, err := ioutil.ReadFile(address)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var plan []*syncer.Action
+
+	err = json.Unmarshal(content, &plan)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode plan: %v", err)
+	}
+
+	files := make([]string, 0)
+	actions := make(map[string][]*syncer.Action)
+
+	for _, action := range plan {
+		if _, exists := actions[action.Address]; !exists {
+			files = append(files, action.Address)
+		}
+
+		actions[action.Address] = append(actions[action.Address], action)
+	}
+
+	return files, actions, nil
+}
+
+// parseInterspersed parses the flags of a command that may come before or after its arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+
+	for {
+		// ExitOnError makes Parse exit on a bad flag
+		_ = flags.Parse(args)
+		args = flags.Args()
+
+		if len(args) == 0 {
+			return positional
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printHistory(w io.Writer, entries []*syncer.HistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	_, err := fmt.Fprintln(tw, "ID\tMODIFIED\tSIZE\tHOST\tSIGNATURE")
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n",
+			entry.Version.Id,
+			entry.Version.ModifiedTime.UTC().Format(time.RFC3339),
+			entry.Version.Size,
+			entry.Host,
+			entry.Signature)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func insideDirectory(fullAddress string, dirs []string) bool {
+	for _, dirName := range dirs {
+		if strings.HasPrefix(fullAddress, dirName+string(filepath.Separator)) {
+			return true
 		}
+	}
+
+	return false
+}
+
+type cliFlags struct {
+	signKey               *string
+	keyring               *string
+	folderName            *string
+	conflictPolicy        *string
+	defaultConflictPolicy *string
+	retention             *string
+	syncRoot              *string
+	remoteLayout          *string
+	dryRun                *bool
+	plan                  *bool
+	applyPlan             *string
+	migrateLayout         *bool
+	workers               *int
+	remote                remoteFlags
+}
+
+// app holds what the commands share, the remote storage is only set up by the commands using it
+type app struct {
+	mtStore *metadata.SqliteMetadataStore
+	// config is the store itself, or an overlay keeping the settings of read only runs out of it
+	config  metadata.ConfigStore
+	dirName string
+	flags   cliFlags
+	rmt     remote.Remote
+	layout  *syncer.Layout
+	keyring *signing.Keyring
+	// folderMissing is set when a read only run found no sync folder, so no file has remote versions
+	folderMissing bool
+	// reportOnly is set for commands that only report on the remote storage, they never change it
+	reportOnly bool
+
+	planMutex sync.Mutex
+	plan      []*syncer.Action
+}
+
+// readOnly is set when nothing may be changed, planning and reporting only read just like a dry run
+func (a *app) readOnly() bool {
+	return *a.flags.dryRun || *a.flags.plan || a.reportOnly
+}
+
+// connect sets up the remote storage and its layout, files holds the arguments of the command
+func (a *app) connect(files []string) error {
+	if a.rmt != nil {
+		return nil
+	}
+
+	rmt, err := newRemote(a.mtStore, a.config, a.dirName, a.flags.remote, a.readOnly())
+
+	if err != nil {
+		return fmt.Errorf("failed to inialize remote storage: %v", err)
+	}
+
+	a.rmt = rmt
+
+	folderName, err := getConfigOrDefault(a.config, "folder-name", a.flags.folderName, DefaultSyncFolderName)
+
+	if err != nil {
+		return fmt.Errorf("failed to get or write folder name: %v", err)
+	}
+
+	var parentId string
+
+	if a.readOnly() {
+		var exists bool
+		parentId, exists, err = rmt.FindDirectory("", folderName)
+		a.folderMissing = !exists
 	} else {
-		fullAddress := args[0]
-		err = gdrive.SyncFile(fullAddress, parentId, srv, mtStore, signKey)
+		parentId, err = rmt.GetOrCreateDirectory("", folderName)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get parent directory: %v", err)
+	}
+
+	signKey, err := getConfigOrDefault(a.config, "sign-key", a.flags.signKey, DefaultSignKey)
+
+	if err != nil {
+		return fmt.Errorf("failed to get or write signing key: %v", err)
+	}
+
+	keyringFile, err := getConfigOrDefault(a.config, "keyring", a.flags.keyring, path.Join(os.Getenv("HOME"), ".bin", "keyring.gpg"))
+
+	if err != nil {
+		return fmt.Errorf("failed to get or write keyring path: %v", err)
+	}
+
+	// Without a keyring nothing is signed, and signed versions are downloaded without verifying them
+	if _, err := os.Stat(keyringFile); err == nil || signKey != "" {
+		a.keyring, err = signing.NewKeyring(keyringFile, signKey, []byte(os.Getenv(PassphraseVariable)))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	tracked, err := trackedFiles(a.mtStore, false)
+
+	if err != nil {
+		return fmt.Errorf("failed to read all synced filenames: %v", err)
+	}
+
+	layoutName, recorded, err := a.readRemoteLayout(parentId)
+
+	if err != nil {
+		return fmt.Errorf("failed to get or write remote layout: %v", err)
+	}
+
+	// Migrating records the tree layout once the files moved
+	if !recorded && !a.readOnly() && !*a.flags.migrateLayout {
+		err = syncer.WriteLayout(rmt, parentId, layoutName)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if layoutName != syncer.TreeLayout {
+		a.layout = syncer.NewFlatLayout(rmt, parentId, append(tracked, files...))
+		return nil
+	}
+
+	syncRoot, err := getConfigOrDefault(a.config, "sync-root", a.flags.syncRoot, os.Getenv("HOME"))
+
+	if err != nil {
+		return fmt.Errorf("failed to get or write sync root: %v", err)
+	}
+
+	a.layout = syncer.NewTreeLayout(rmt, parentId, syncRoot)
+
+	if *a.flags.migrateLayout {
+		err = syncer.MigrateFlatLayout(rmt, parentId, a.layout, tracked)
+
+		if err != nil {
+			return fmt.Errorf("failed to migrate to the tree layout: %v", err)
+		}
+
+		err = syncer.WriteLayout(rmt, parentId, syncer.TreeLayout)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *app) close() {
+	if closer, ok := a.rmt.(io.Closer); ok {
+		err := closer.Close()
 
 		if err != nil {
-			log.Fatalf("failed to sync filename %s: %v", fullAddress, err)
+			log.Printf("failed to close remote storage: %v", err)
 		}
 	}
+}
+
+// policyFiles expands directory arguments into their files, the policies are kept per file.
+// Without arguments it returns a single empty address, standing for the global policy
+func policyFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{""}, nil
+	}
+
+	files := make([]string, 0)
+
+	for _, address := range args {
+		if stats, err := os.Stat(address); err == nil && stats.IsDir() {
+			dirFiles, err := syncer.ListDirectoryFiles(address)
 
+			if err != nil {
+				return nil, fmt.Errorf("failed to list directory %s: %v", address, err)
+			}
+
+			files = append(files, dirFiles...)
+		} else {
+			files = append(files, address)
+		}
+	}
+
+	return files, nil
+}
+
+// savePolicies stores the policy flags for every file argument, those inside directory arguments included,
+// or globally without arguments. Read only runs keep them in the config overlay so they only apply to the run
+func (a *app) savePolicies(args []string) error {
+	if *a.flags.defaultConflictPolicy == "" && *a.flags.retention == "" {
+		return nil
+	}
+
+	files, err := policyFiles(args)
+
+	if err != nil {
+		return err
+	}
+
+	if *a.flags.defaultConflictPolicy != "" {
+		policy, err := syncer.ParseConflictPolicy(*a.flags.defaultConflictPolicy)
+
+		if err != nil {
+			return fmt.Errorf("invalid -default-conflict-policy: %v", err)
+		}
+
+		for _, fullAddress := range files {
+			err = syncer.WriteConflictPolicy(a.config, fullAddress, policy)
+
+			if err != nil {
+				return fmt.Errorf("failed to save conflict policy: %v", err)
+			}
+		}
+	}
+
+	if *a.flags.retention != "" {
+		retention, err := cleanup.ParseRetentionPolicy(*a.flags.retention)
+
+		if err != nil {
+			return fmt.Errorf("invalid -retention: %v", err)
+		}
+
+		for _, fullAddress := range files {
+			err = cleanup.WriteRetentionPolicy(a.config, fullAddress, retention)
+
+			if err != nil {
+				return fmt.Errorf("failed to save retention policy: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *app) conflictPolicy(fullAddress string) (syncer.ConflictPolicy, error) {
+	if *a.flags.conflictPolicy != "" {
+		return syncer.ParseConflictPolicy(*a.flags.conflictPolicy)
+	}
+
+	return syncer.ReadConflictPolicy(a.config, fullAddress)
+}
+
+// folder returns the remote folder of the file, read only runs only look it up and exists is false when it's missing
+func (a *app) folder(fullAddress string) (string, bool, error) {
+	if !a.readOnly() {
+		folderId, err := a.layout.Folder(fullAddress)
+
+		return folderId, err == nil, err
+	}
+
+	if a.folderMissing {
+		return "", false, nil
+	}
+
+	return a.layout.Lookup(fullAddress)
+}
+
+// syncFile syncs a single file, or logs or adds its plan when -dry-run or -plan is set
+func (a *app) syncFile(fullAddress string) error {
+	folderId, exists, err := a.folder(fullAddress)
+
+	if err != nil {
+		return err
+	}
+
+	policy, err := a.conflictPolicy(fullAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to read conflict policy: %v", err)
+	}
+
+	retention, err := cleanup.ReadRetentionPolicy(a.config, fullAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to read retention policy: %v", err)
+	}
+
+	if !a.readOnly() {
+		return syncer.SyncFile(fullAddress, folderId, a.rmt, a.mtStore, a.keyring, policy, retention, false)
+	}
+
+	var state *syncer.FileState
+
+	if exists {
+		state, err = syncer.Inspect(a.rmt, fullAddress, folderId, a.mtStore)
+	} else {
+		state, err = syncer.InspectLocal(fullAddress, a.mtStore)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	actions := syncer.PlanFile(state, policy, retention, time.Now())
+
+	if *a.flags.dryRun {
+		syncer.LogDryRun(fullAddress, actions)
+		return nil
+	}
+
+	a.planMutex.Lock()
+	a.plan = append(a.plan, actions...)
+	a.planMutex.Unlock()
+
+	return nil
+}
+
+// syncFiles syncs the files on a.workers goroutines, a failure or conflict leaves the file untouched
+// without stopping the others, they are reported once every file was synced
+func (a *app) syncFiles(files []string, syncFunc func(fullAddress string) error) error {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	conflicts := 0
+	failures := 0
+	addresses := make(chan string)
+
+	for i := 0; i < *a.flags.workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for fullAddress := range addresses {
+				log.Printf("syncing file: %s", fullAddress)
+
+				err := syncFunc(fullAddress)
+
+				mutex.Lock()
+
+				if conflict, ok := err.(*syncer.ConflictError); ok {
+					log.Printf("skipping %s: %v", fullAddress, conflict)
+					conflicts++
+				} else if err != nil {
+					log.Printf("failed to sync filename %s: %v", fullAddress, err)
+					failures++
+				}
+
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, fullAddress := range files {
+		addresses <- fullAddress
+	}
+
+	close(addresses)
+	wg.Wait()
+
+	if failures > 0 && conflicts > 0 {
+		return fmt.Errorf("%d files failed to sync and %d files have conflicts", failures, conflicts)
+	} else if failures > 0 {
+		return fmt.Errorf("%d files failed to sync", failures)
+	} else if conflicts > 0 {
+		return fmt.Errorf("%d files have conflicts", conflicts)
+	}
+
+	return nil
+}
+
+// trackDirectory tracks a directory given to track or sync, returning its files
+func (a *app) trackDirectory(dirName string) ([]string, error) {
+	if a.readOnly() {
+		log.Printf("dry run: would track directory %s", dirName)
+	} else {
+		err := a.mtStore.TrackDirectory(dirName)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return syncer.ListDirectoryFiles(dirName)
+}
+
+// filesToSync expands directory arguments into their files, tracking the directories,
+// without arguments it returns every tracked file
+func (a *app) filesToSync(args []string) ([]string, error) {
+	var err error
+
+	files := make([]string, 0)
+
+	if len(args) == 0 {
+		files, err = trackedFiles(a.mtStore, !a.readOnly())
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read all synced filenames: %v", err)
+		}
+	}
+
+	for _, address := range args {
+		if stats, err := os.Stat(address); err == nil && stats.IsDir() {
+			dirFiles, err := a.trackDirectory(address)
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to track directory %s: %v", address, err)
+			}
+
+			files = append(files, dirFiles...)
+		} else {
+			files = append(files, address)
+		}
+	}
+
+	return files, nil
+}
+
+// sync syncs the given files and directories, or everything tracked without arguments
+func (a *app) sync(args []string) error {
+	err := a.connect(args)
+
+	if err != nil {
+		return err
+	}
+
+	if *a.flags.applyPlan != "" {
+		planFiles, actions, err := readPlan(*a.flags.applyPlan)
+
+		if err != nil {
+			return fmt.Errorf("failed to read plan: %v", err)
+		}
+
+		return a.syncFiles(planFiles, func(fullAddress string) error {
+			for _, action := range actions[fullAddress] {
+				// Files planned before their remote folder existed have no parent yet
+				if action.ParentId == "" {
+					folderId, err := a.layout.Folder(fullAddress)
+
+					if err != nil {
+						return fmt.Errorf("failed to locate remote folder of %s: %v", fullAddress, err)
+					}
+
+					action.ParentId = folderId
+				}
+			}
+
+			return syncer.ExecutePlan(a.rmt, a.mtStore, a.keyring, actions[fullAddress])
+		})
+	}
+
+	files, err := a.filesToSync(args)
+
+	if err != nil {
+		return err
+	}
+
+	err = a.syncFiles(files, a.syncFile)
+
+	if err != nil || !*a.flags.plan {
+		return err
+	}
+
+	// Files are planned concurrently, keep the output the same from run to run
+	sort.SliceStable(a.plan, func(i, j int) bool {
+		return a.plan[i].Address < a.plan[j].Address
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(a.plan)
+}
+
+// watch syncs every file once, then syncs each file again whenever it changes locally,
+// and polls the remote storage every pollInterval for files uploaded elsewhere
+func (a *app) watch(args []string, delay time.Duration, pollInterval time.Duration) error {
+	err := a.connect(args)
+
+	if err != nil {
+		return err
+	}
+
+	files, err := a.filesToSync(args)
+
+	if err != nil {
+		return err
+	}
+
+	folders := make(map[string]string)
+
+	for _, fullAddress := range files {
+		folderId, exists, err := a.folder(fullAddress)
+
+		if err != nil {
+			return fmt.Errorf("failed to locate remote folder of %s: %v", fullAddress, err)
+		}
+
+		if exists {
+			folders[fullAddress] = folderId
+		}
+	}
+
+	lister, hasChanges := a.rmt.(remote.ChangeLister)
+
+	if hasChanges {
+		// Changes up to now are covered by the full sync below
+		err = syncer.PollChanges(lister, a.config, folders, func(fullAddress string) error {
+			return nil
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to poll remote changes: %v", err)
+		}
+	}
+
+	// Files changed while not watching are only caught by a full sync
+	err = a.syncFiles(files, a.syncFile)
+
+	if err != nil {
+		log.Printf("initial sync: %v", err)
+	}
+
+	// New files are only picked up inside tracked directories
+	dirs := make([]string, 0)
+
+	if len(args) == 0 {
+		dirs, err = a.mtStore.GetTrackedDirectories()
+
+		if err != nil {
+			return fmt.Errorf("failed to read tracked directories: %v", err)
+		}
+	}
+
+	for _, address := range args {
+		if stats, err := os.Stat(address); err == nil && stats.IsDir() {
+			dirs = append(dirs, address)
+		}
+	}
+
+	watched := make(map[string]bool)
+
+	for _, fullAddress := range files {
+		watched[fullAddress] = true
+	}
+
+	watcher, err := watch.NewWatcher(files, dirs, delay)
+
+	if err != nil {
+		return err
+	}
+
+	if pollInterval > 0 {
+		watcher.Poll(pollInterval, func() {
+			var err error
+
+			// Without a changes feed every file has to be listed
+			if hasChanges {
+				err = syncer.PollChanges(lister, a.config, folders, a.syncFile)
+			} else {
+				err = a.syncFiles(files, a.syncFile)
+			}
+
+			if err != nil {
+				log.Printf("failed to poll remote changes: %v", err)
+			}
+		})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+
+		err := watcher.Close()
+
+		if err != nil {
+			log.Printf("failed to close watcher: %v", err)
+		}
+	}()
+
+	log.Printf("watching %d files", len(files))
+
+	return watcher.Run(func(fullAddress string) {
+		if !watched[fullAddress] {
+			log.Printf("watching new file %s", fullAddress)
+
+			watched[fullAddress] = true
+			files = append(files, fullAddress)
+			folderId, exists, err := a.folder(fullAddress)
+
+			if err != nil {
+				log.Printf("failed to locate remote folder of %s: %v", fullAddress, err)
+			} else if exists {
+				folders[fullAddress] = folderId
+			}
+		}
+
+		// A failure only affects the file, the next change retries it
+		err := a.syncFiles([]string{fullAddress}, a.syncFile)
+
+		if err != nil {
+			log.Printf("%v", err)
+		}
+	})
+}
+
+// track adds files and directories to the tracked set without syncing them
+func (a *app) track(args []string) error {
+	for _, address := range args {
+		stats, err := os.Stat(address)
+
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %v", address, err)
+		}
+
+		if stats.IsDir() {
+			_, err = a.trackDirectory(address)
+		} else if a.readOnly() {
+			log.Printf("dry run: would track file %s", address)
+		} else {
+			err = a.mtStore.TrackFile(address)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to track %s: %v", address, err)
+		}
+	}
+
+	return nil
+}
+
+// untrack forgets files and directories, deleteRemote also deletes the remote history of every untracked file
+func (a *app) untrack(args []string, deleteRemote bool) error {
+	dirs, err := a.mtStore.GetTrackedDirectories()
+
+	if err != nil {
+		return err
+	}
+
+	tracked, err := trackedFiles(a.mtStore, false)
+
+	if err != nil {
+		return fmt.Errorf("failed to read all synced filenames: %v", err)
+	}
+
+	trackedDirs := hashset.New()
+
+	for _, dirName := range dirs {
+		trackedDirs.Add(dirName)
+	}
+
+	files := make([]string, 0)
+
+	// Files inside tracked directories would be listed again, so they are excluded in the directories ignore files
+	excludeFrom := make(map[string][]string)
+
+	for _, address := range args {
+		if trackedDirs.Contains(address) {
+			for _, fullAddress := range tracked {
+				if insideDirectory(fullAddress, []string{address}) {
+					files = append(files, fullAddress)
+				}
+			}
+
+			continue
+		}
+
+		for _, dirName := range dirs {
+			if insideDirectory(address, []string{dirName}) {
+				excludeFrom[address] = append(excludeFrom[address], dirName)
+			}
+		}
+
+		files = append(files, address)
+	}
+
+	if deleteRemote && !a.readOnly() {
+		err = a.connect(files)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, fullAddress := range files {
+		if a.readOnly() {
+			log.Printf("dry run: would untrack %s", fullAddress)
+			continue
+		}
+
+		if deleteRemote {
+			folderId, err := a.layout.Folder(fullAddress)
+
+			if err != nil {
+				return err
+			}
+
+			err = syncer.DeleteHistory(a.rmt, folderId, path.Base(fullAddress))
+
+			if err != nil {
+				return fmt.Errorf("failed to delete remote history of %s: %v", fullAddress, err)
+			}
+		}
+
+		for _, dirName := range excludeFrom[fullAddress] {
+			excluded, err := ignore.Excluded(dirName, fullAddress)
+
+			if err != nil {
+				return fmt.Errorf("failed to read ignore files of %s: %v", dirName, err)
+			}
+
+			if excluded {
+				continue
+			}
+
+			err = ignore.Exclude(dirName, fullAddress)
+
+			if err != nil {
+				return fmt.Errorf("failed to exclude %s: %v", fullAddress, err)
+			}
+
+			log.Printf("excluded %s in %s", fullAddress, filepath.Join(dirName, ignore.FileName))
+		}
+
+		err = a.mtStore.UntrackFile(fullAddress)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, address := range args {
+		if trackedDirs.Contains(address) && !a.readOnly() {
+			err = a.mtStore.UntrackDirectory(address)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// list prints the tracked directories, then every tracked file
+func (a *app) list() error {
+	dirs, err := a.mtStore.GetTrackedDirectories()
+
+	if err != nil {
+		return err
+	}
+
+	files, err := trackedFiles(a.mtStore, false)
+
+	if err != nil {
+		return fmt.Errorf("failed to read all synced filenames: %v", err)
+	}
+
+	sort.Strings(dirs)
+	sort.Strings(files)
+
+	for _, dirName := range dirs {
+		fmt.Println(dirName + string(filepath.Separator))
+	}
+
+	for _, fullAddress := range files {
+		fmt.Println(fullAddress)
+	}
+
+	return nil
+}
+
+// status prints the sync state of every tracked file, or those given, and fails when any isn't clean
+func (a *app) status(args []string) error {
+	tracked, err := trackedFiles(a.mtStore, false)
+
+	if err != nil {
+		return fmt.Errorf("failed to read all synced filenames: %v", err)
+	}
+
+	files := tracked
+
+	if len(args) > 0 {
+		files = make([]string, 0)
+
+		for _, fullAddress := range tracked {
+			for _, address := range args {
+				if fullAddress == address || insideDirectory(fullAddress, []string{address}) {
+					files = append(files, fullAddress)
+					break
+				}
+			}
+		}
+	}
+
+	err = a.connect(files)
+
+	if err != nil {
+		return err
+	}
+
+	// Files are grouped by remote folder so each folder is listed once
+	folders := make([]string, 0)
+	folderFiles := make(map[string][]string)
+	statuses := make(map[string]syncer.FileStatus)
+
+	for _, fullAddress := range files {
+		folderId, exists, err := a.folder(fullAddress)
+
+		if err != nil {
+			return fmt.Errorf("failed to locate remote folder of %s: %v", fullAddress, err)
+		}
+
+		if !exists {
+			// Nothing was ever uploaded from the folder
+			state, err := syncer.InspectLocal(fullAddress, a.mtStore)
+
+			if err != nil {
+				return err
+			}
+
+			statuses[fullAddress] = syncer.Status(state)
+			continue
+		}
+
+		if _, ok := folderFiles[folderId]; !ok {
+			folders = append(folders, folderId)
+		}
+
+		folderFiles[folderId] = append(folderFiles[folderId], fullAddress)
+	}
+
+	for _, folderId := range folders {
+		states, err := syncer.InspectFolder(a.rmt, folderId, folderFiles[folderId], a.mtStore)
+
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			statuses[state.Address] = syncer.Status(state)
+		}
+	}
+
+	sort.Strings(files)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	outOfSync := 0
+
+	for _, fullAddress := range files {
+		if statuses[fullAddress] != syncer.StatusClean {
+			outOfSync++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\n", statuses[fullAddress], fullAddress)
+	}
+
+	err = w.Flush()
+
+	if err != nil {
+		return err
+	}
+
+	if outOfSync > 0 {
+		return fmt.Errorf("%d files are out of sync", outOfSync)
+	}
+
+	return nil
+}
+
+func (a *app) history(fullAddress string) error {
+	err := a.connect([]string{fullAddress})
+
+	if err != nil {
+		return err
+	}
+
+	folderId, exists, err := a.folder(fullAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to locate remote folder of %s: %v", fullAddress, err)
+	}
+
+	if !exists {
+		return printHistory(os.Stdout, []*syncer.HistoryEntry{})
+	}
+
+	entries, err := syncer.History(a.rmt, folderId, path.Base(fullAddress), a.keyring)
+
+	if err != nil {
+		return fmt.Errorf("failed to list versions of %s: %v", fullAddress, err)
+	}
+
+	return printHistory(os.Stdout, entries)
+}
+
+func (a *app) restore(fullAddress string, selector string, reupload bool) error {
+	err := a.connect([]string{fullAddress})
+
+	if err != nil {
+		return err
+	}
+
+	folderId, exists, err := a.folder(fullAddress)
+
+	if err != nil {
+		return fmt.Errorf("failed to locate remote folder of %s: %v", fullAddress, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("%s has no remote versions", fullAddress)
+	}
+
+	versions, err := a.rmt.ListVersions(folderId, path.Base(fullAddress))
+
+	if err != nil {
+		return fmt.Errorf("failed to list versions of %s: %v", fullAddress, err)
+	}
+
+	version, err := syncer.FindVersion(versions, selector)
+
+	if err != nil {
+		return fmt.Errorf("failed to find version of %s: %v", fullAddress, err)
+	}
+
+	if a.readOnly() {
+		log.Printf("dry run: would restore %s to version %s from %s", fullAddress, version.Id, version.ModifiedTime.UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	return syncer.RestoreVersion(a.rmt, fullAddress, folderId, version, a.mtStore, a.keyring, reupload)
+}
+
+// absolutePaths makes the file arguments independent of the working directory, they are the keys of the metadata store
+func absolutePaths(args []string) []string {
+	paths := make([]string, 0, len(args))
+
+	for _, address := range args {
+		absAddress, err := filepath.Abs(address)
+
+		if err != nil {
+			log.Fatalf("failed to get absolute path of %s: %v", address, err)
+		}
+
+		paths = append(paths, absAddress)
+	}
+
+	return paths
+}
+
+const usage = `usage: %s [flags] [command] [args]
+
+commands:
+  sync [path...]        sync the given files and directories, or every tracked file (default)
+  track <path...>       track files and directories without syncing them
+  untrack <path...>     stop tracking files and directories, -delete-remote also deletes their history
+                        files inside a tracked directory are excluded in its .syncignore
+  list                  print the tracked directories and files
+  status [path...]      print whether each tracked file is clean, exits non-zero when any isn't
+  watch [path...]       sync files as they change, until interrupted
+  log <file>            list the remote versions of a file
+  restore <file>        restore a version given with -version, -reupload uploads it right away
+
+flags:
+`
+
+func main() {
+	execPath, err := osext.Executable()
+
+	if err != nil {
+		log.Fatalf("failed to get executable path")
+	}
+
+	dirName := path.Dir(execPath)
+	// log.Printf("dir is:%s", dirName)
+
+	dbDir := path.Join(os.Getenv("HOME"), ".bin")
+	mtStore, err := metadata.NewSQLite3Store(dbDir)
+
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+
+	defer func() {
+		err := mtStore.Close()
+
+		if err != nil {
+			log.Printf("failed to close metastore: %v", err)
+		}
+	}()
+
+	flags := cliFlags{
+		signKey:               flag.String("sign-key", "", "fingerprint, long key id, user id or email of the key signing uploads, its passphrase is read from $"+PassphraseVariable),
+		keyring:               flag.String("keyring", "", "keys exported with gpg --export-secret-keys, or --export to only verify, defaults to ~/.bin/keyring.gpg"),
+		folderName:            flag.String("folder-name", "", "folder name for sync"),
+		conflictPolicy:        flag.String("conflict-policy", "", "conflict policy for this run only: keep-local, keep-remote, keep-both or fail"),
+		defaultConflictPolicy: flag.String("default-conflict-policy", "", "save the conflict policy for the given files and the files of the given directories, or globally without any"),
+		retention:             flag.String("retention", "", "save the retention policy for the given files and the files of the given directories, or globally without any, like last=5,within=10d,daily=7,weekly=4,monthly=12"),
+		syncRoot:              flag.String("sync-root", "", "local directory mirrored by the tree remote layout, defaults to $HOME"),
+		remoteLayout:          flag.String("remote-layout", "", "remote layout: tree mirrors local directories, flat keeps every file in one folder"),
+		dryRun:                flag.Bool("dry-run", false, "print what sync and purge would do without transferring, deleting or writing metadata"),
+		plan:                  flag.Bool("plan", false, "print the sync plan as JSON instead of syncing"),
+		applyPlan:             flag.String("apply-plan", "", "execute a plan saved from -plan"),
+		migrateLayout:         flag.Bool("migrate-layout", false, "move the history of flat layout files into the tree layout"),
+		workers:               flag.Int("workers", 4, "number of files synced at the same time"),
+		remote: remoteFlags{
+			backend:        flag.String("backend", "", "storage backend: drive, local, s3, webdav or sftp"),
+			localDir:       flag.String("local-dir", "", "directory used by the local backend"),
+			s3Endpoint:     flag.String("s3-endpoint", "", "s3 endpoint url, http:// disables TLS"),
+			s3Bucket:       flag.String("s3-bucket", "", "s3 bucket for sync"),
+			s3AccessKey:    flag.String("s3-access-key", "", "s3 access key id"),
+			s3SecretKey:    flag.String("s3-secret-key", "", "s3 secret access key for this run only, not stored, defaults to $"+S3SecretKeyVariable),
+			webdavURL:      flag.String("webdav-url", "", "webdav collection url for sync"),
+			webdavUser:     flag.String("webdav-user", "", "webdav basic auth user"),
+			webdavPassword: flag.String("webdav-password", "", "webdav basic auth password for this run only, not stored, defaults to $"+WebDAVPasswordVariable),
+			sftpHost:       flag.String("sftp-host", "", "sftp host, optionally with :port"),
+			sftpUser:       flag.String("sftp-user", "", "sftp user name"),
+			sftpKey:        flag.String("sftp-key", "", "ssh private key path, defaults to ~/.ssh/id_rsa, its passphrase is read from $"+SFTPPassphraseVariable+", ssh-agent keys are tried first"),
+			sftpDir:        flag.String("sftp-dir", "", "directory on the sftp host used for sync"),
+			retries:        flag.Int("retries", retry.DefaultPolicy.MaxRetries, "how many times drive calls failing on rate limits or server errors are retried"),
+			retryDelay:     flag.Duration("retry-delay", retry.DefaultPolicy.BaseDelay, "wait before the first retry, doubling on every next one"),
+			chunkSize:      flag.Int64("chunk-size", gdrive.DefaultChunkSize, "drive files bigger than this are uploaded in resumable chunks of this many bytes, a multiple of 262144, 0 disables")}}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	args := flag.Args()
+
+	// Without a known command the arguments are files to sync, like before commands existed
+	var command = "sync"
+
+	switch {
+	case len(args) == 0:
+	case args[0] == "sync", args[0] == "track", args[0] == "untrack", args[0] == "list",
+		args[0] == "status", args[0] == "watch", args[0] == "log", args[0] == "restore":
+		command, args = args[0], args[1:]
+	}
+
+	untrackFlags := flag.NewFlagSet("untrack", flag.ExitOnError)
+	deleteRemoteFlag := untrackFlags.Bool("delete-remote", false, "also delete the remote history of the untracked files")
+
+	watchFlags := flag.NewFlagSet("watch", flag.ExitOnError)
+	debounceFlag := watchFlags.Duration("debounce", watch.DefaultDelay, "how long a file has to stay unchanged before it's synced")
+	pollFlag := watchFlags.Duration("poll", time.Minute, "how often to look for files uploaded from other machines, 0 disables")
+
+	restoreFlags := flag.NewFlagSet("restore", flag.ExitOnError)
+	restoreVersionFlag := restoreFlags.String("version", "", "id or modified time of the version to restore")
+	reuploadFlag := restoreFlags.Bool("reupload", false, "upload the restored file as the newest version right away")
+
+	switch command {
+	case "untrack":
+		args = parseInterspersed(untrackFlags, args)
+	case "watch":
+		args = parseInterspersed(watchFlags, args)
+	case "restore":
+		args = parseInterspersed(restoreFlags, args)
+	}
+
+	args = absolutePaths(args)
+
+	switch {
+	case (command == "track" || command == "untrack" || command == "log") && len(args) == 0,
+		command == "log" && len(args) != 1,
+		command == "list" && len(args) != 0:
+		flag.Usage()
+		os.Exit(2)
+	case command == "restore" && (len(args) != 1 || *restoreVersionFlag == ""):
+		log.Fatalf("usage: %s restore <file> -version <id|timestamp> [-reupload]", os.Args[0])
+	}
+
+	if *flags.workers < 1 {
+		log.Fatalf("-workers must be at least 1")
+	}
+
+	if *flags.remote.retries < 0 {
+		log.Fatalf("-retries can't be negative")
+	}
+
+	a := &app{
+		mtStore:    mtStore,
+		config:     mtStore,
+		dirName:    dirName,
+		flags:      flags,
+		reportOnly: command == "status" || command == "log",
+		plan:       make([]*syncer.Action, 0)}
+
+	if a.readOnly() {
+		// Settings given to a dry run apply to it without being stored
+		a.config = metadata.NewConfigOverlay(mtStore)
+	}
+
+	if *flags.migrateLayout && a.readOnly() {
+		log.Fatalf("-migrate-layout can't be combined with -dry-run, -plan, status or log")
+	}
+
+	if *flags.applyPlan != "" && (a.readOnly() || command != "sync" || len(args) > 0) {
+		log.Fatalf("-apply-plan can't be combined with -dry-run, -plan, commands or file arguments")
+	}
+
+	if command == "watch" && *flags.plan {
+		log.Fatalf("watch can't be combined with -plan")
+	}
+
+	defer a.close()
+
+	err = a.savePolicies(args)
+
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	switch command {
+	case "sync":
+		err = a.sync(args)
+	case "track":
+		err = a.track(args)
+	case "untrack":
+		err = a.untrack(args, *deleteRemoteFlag)
+	case "list":
+		err = a.list()
+	case "status":
+		err = a.status(args)
+	case "watch":
+		err = a.watch(args, *debounceFlag, *pollFlag)
+	case "log":
+		err = a.history(args[0])
+	case "restore":
+		err = a.restore(args[0], *restoreVersionFlag, *reuploadFlag)
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", command, err)
+	}
 }
@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"log"
+	"path"
+	"strings"
+)
+
+// ChangeTokenKey is the config key of the token the next poll lists changes from
+const ChangeTokenKey = "changes-token"
+
+// PollChanges calls syncFunc for the files in folders, a file address to remote folder id map, changed remotely
+// since the last poll. The token only moves on once every changed file synced, so failures are retried next poll.
+// The first poll just saves the token, whatever changed before is left to a full sync
+func PollChanges(lister remote.ChangeLister, configStore metadata.ConfigStore, folders map[string]string, syncFunc func(fullAddress string) error) error {
+	exists, token, err := configStore.ReadStringConfig(ChangeTokenKey)
+
+	if err != nil {
+		return fmt.Errorf("failed to read changes token: %v", err)
+	}
+
+	if !exists {
+		token, err = lister.StartChangeToken()
+
+		if err != nil {
+			return err
+		}
+
+		return configStore.WriteStringConfig(ChangeTokenKey, token)
+	}
+
+	changes, nextToken, err := lister.ListChanges(token)
+
+	if err != nil {
+		return err
+	}
+
+	byFolderName := make(map[string]string)
+
+	for fullAddress, folderId := range folders {
+		byFolderName[folderId+"/"+path.Base(fullAddress)] = fullAddress
+	}
+
+	changed := make(map[string]bool)
+
+	for _, change := range changes {
+		for _, parentId := range change.ParentIds {
+			// A new signature is as much a change of its file
+			key := parentId + "/" + strings.TrimSuffix(change.Name, ".sig")
+
+			if fullAddress, ok := byFolderName[key]; ok && !changed[fullAddress] {
+				changed[fullAddress] = true
+
+				err = syncFunc(fullAddress)
+
+				if _, ok := err.(*ConflictError); ok {
+					log.Printf("skipping %s: %v", fullAddress, err)
+				} else if err != nil {
+					return fmt.Errorf("failed to sync filename %s: %v", fullAddress, err)
+				}
+			}
+		}
+	}
+
+	return configStore.WriteStringConfig(ChangeTokenKey, nextToken)
+}
@@ -0,0 +1,132 @@
+package syncer
+
+import (
+	"errors"
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/remote"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// poll lists the changes for the files of the machine kept in the flat sync folder, returning the ones synced
+func (m *testMachine) poll(rmt remote.Remote, parentId string, names []string, fail error) []string {
+	folders := make(map[string]string)
+
+	for _, name := range names {
+		folders[m.address(name)] = parentId
+	}
+
+	synced := make([]string, 0)
+
+	err := PollChanges(rmt.(remote.ChangeLister), m.mtStore, folders, func(fullAddress string) error {
+		synced = append(synced, fullAddress)
+
+		if fail != nil {
+			return fail
+		}
+
+		return SyncFile(fullAddress, parentId, rmt, m.mtStore, nil, Fail, cleanup.DefaultRetentionPolicy, false)
+	})
+
+	if err != nil && fail == nil {
+		m.t.Fatalf("failed to poll changes: %v", err)
+	}
+
+	return synced
+}
+
+func (m *testMachine) changeToken() string {
+	exists, token, err := m.mtStore.ReadStringConfig(ChangeTokenKey)
+
+	if err != nil {
+		m.t.Fatalf("failed to read changes token: %v", err)
+	}
+
+	if !exists {
+		m.t.Fatalf("expected the changes token to be saved")
+	}
+
+	return token
+}
+
+func TestPollChangesSyncsChangedTrackedFiles(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	first := newTestMachine(t)
+	second := newTestMachine(t)
+	modTime := time.Now().Add(-2 * time.Hour)
+	names := []string{"changed.txt", "unchanged.txt"}
+
+	for _, name := range names {
+		first.write(name, "first", modTime)
+		first.sync(rmt, parentId, name, cleanup.DefaultRetentionPolicy)
+		second.sync(rmt, parentId, name, cleanup.DefaultRetentionPolicy)
+	}
+
+	// The first poll only saves where changes start from
+	if synced := second.poll(rmt, parentId, names, nil); len(synced) != 0 {
+		t.Fatalf("expected the first poll to sync nothing, got %v", synced)
+	}
+
+	started := second.changeToken()
+
+	if synced := second.poll(rmt, parentId, names, nil); len(synced) != 0 {
+		t.Fatalf("expected no changes, got %v", synced)
+	}
+
+	// Versions are stamped with the upload time in seconds, the next one has to land in a later second
+	time.Sleep(1100 * time.Millisecond)
+
+	first.write("changed.txt", "second", modTime.Add(time.Hour))
+	first.sync(rmt, parentId, "changed.txt", cleanup.DefaultRetentionPolicy)
+	first.write("untracked.txt", "other", modTime)
+	first.sync(rmt, parentId, "untracked.txt", cleanup.DefaultRetentionPolicy)
+
+	synced := second.poll(rmt, parentId, names, nil)
+
+	if !reflect.DeepEqual(synced, []string{second.address("changed.txt")}) {
+		t.Fatalf("expected only the changed tracked file to sync, got %v", synced)
+	}
+
+	if second.read("changed.txt") != "second" {
+		t.Errorf("expected the change to be downloaded, got '%s'", second.read("changed.txt"))
+	}
+
+	if second.changeToken() == started {
+		t.Errorf("expected the changes token to move on")
+	}
+
+	if synced := second.poll(rmt, parentId, names, nil); len(synced) != 0 {
+		t.Errorf("expected changes already synced not to be listed again, got %v", synced)
+	}
+}
+
+func TestPollChangesKeepsTokenOnFailure(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	first := newTestMachine(t)
+	second := newTestMachine(t)
+	names := []string{"file.txt"}
+
+	second.poll(rmt, parentId, names, nil)
+	started := second.changeToken()
+
+	first.write("file.txt", "first", time.Now().Add(-time.Hour))
+	first.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	if synced := second.poll(rmt, parentId, names, errors.New("offline")); len(synced) != 1 {
+		t.Fatalf("expected the changed file to be synced, got %v", synced)
+	}
+
+	if second.changeToken() != started {
+		t.Fatalf("expected a failed sync to keep the changes token")
+	}
+
+	// The failed change is listed again on the next poll
+	if synced := second.poll(rmt, parentId, names, nil); len(synced) != 1 {
+		t.Fatalf("expected the failed file to be retried, got %v", synced)
+	}
+
+	if second.read("file.txt") != "first" {
+		t.Errorf("expected the retried file to be downloaded, got '%s'", second.read("file.txt"))
+	}
+}
@@ -0,0 +1,114 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"time"
+)
+
+type ConflictPolicy string
+
+const (
+	// KeepLocal uploads the local file as the newest version
+	KeepLocal ConflictPolicy = "keep-local"
+	// KeepRemote overwrites the local file with the newest remote version
+	KeepRemote ConflictPolicy = "keep-remote"
+	// KeepBoth saves the remote version next to the local file, then uploads the local file
+	KeepBoth ConflictPolicy = "keep-both"
+	// Fail leaves both sides untouched and returns a ConflictError
+	Fail ConflictPolicy = "fail"
+)
+
+const DefaultConflictPolicy = Fail
+
+const conflictPolicyKey = "conflict-policy"
+
+// ConflictError is returned when the local file and the newest remote version both changed since the last sync
+type ConflictError struct {
+	Address string
+	Remote  *remote.Version
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		"conflict: %s and remote version %s(%s) both changed since the last sync",
+		e.Address,
+		e.Remote.Id,
+		e.Remote.ModifiedTime.UTC().Format(time.RFC3339))
+}
+
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case KeepLocal, KeepRemote, KeepBoth, Fail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy '%s', expecting keep-local, keep-remote, keep-both or fail", value)
+	}
+}
+
+func conflictPolicyFileKey(address string) string {
+	return conflictPolicyKey + ":" + address
+}
+
+// ReadConflictPolicy returns the policy stored for the file, falling back to the global one and then to DefaultConflictPolicy
+func ReadConflictPolicy(db metadata.ConfigStore, address string) (ConflictPolicy, error) {
+	for _, key := range []string{conflictPolicyFileKey(address), conflictPolicyKey} {
+		exists, value, err := db.ReadStringConfig(key)
+
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", key, err)
+		}
+
+		if exists {
+			return ParseConflictPolicy(value)
+		}
+	}
+
+	return DefaultConflictPolicy, nil
+}
+
+// WriteConflictPolicy stores the policy for a single file, or the global one when address is empty
+func WriteConflictPolicy(db metadata.ConfigStore, address string, policy ConflictPolicy) error {
+	key := conflictPolicyKey
+
+	if address != "" {
+		key = conflictPolicyFileKey(address)
+	}
+
+	err := db.WriteStringConfig(key, string(policy))
+
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// conflictCopyAddress names the copy of a remote version kept by KeepBoth after the uploading host and the version time
+func conflictCopyAddress(address string, file *remote.Version) string {
+	host, exists := file.Properties["host"]
+
+	if !exists || host == "" {
+		host = "remote"
+	}
+
+	return fmt.Sprintf("%s.conflict-%s-%s", address, host, file.ModifiedTime.UTC().Format("20060102T150405Z"))
+}
+
+// conflictActions applies the policy to a conflict between the local file and the newest remote version
+func conflictActions(state *FileState, newest *remote.Version, policy ConflictPolicy, reason string) []*Action {
+	switch policy {
+	case KeepLocal:
+		return []*Action{state.action(ActionUpload, newest, reason+", keeping the local file")}
+	case KeepRemote:
+		return []*Action{state.action(ActionDownload, newest, reason+", keeping the cloud version")}
+	case KeepBoth:
+		saveCopy := state.action(ActionSaveConflictCopy, newest, reason+", keeping both")
+		saveCopy.CopyAddress = conflictCopyAddress(state.Address, newest)
+
+		return []*Action{saveCopy, state.action(ActionUpload, newest, reason+", keeping both")}
+	default:
+		return []*Action{state.action(ActionConflict, newest, reason)}
+	}
+}
@@ -0,0 +1,206 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/progress"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+)
+
+func DownloadFile(rmt remote.Remote, address string, file *remote.Version) error {
+	body, err := rmt.Download(file.Id)
+
+	if err != nil {
+		return fmt.Errorf("failed to get newer version from cloud: %v", err)
+	}
+
+	defer func() {
+		err := body.Close()
+
+		if err != nil {
+			log.Printf("error closing download file: %v", err)
+		}
+	}()
+
+	var mode os.FileMode = 0600
+	modeString, exists := file.Properties["mode"]
+
+	if exists {
+		log.Printf("mode string is: %v", modeString)
+		mode64, err := strconv.ParseInt(modeString, 10, 32)
+
+		if err != nil {
+			return fmt.Errorf("failed to parse mode string:%s", modeString)
+		}
+
+		mode = os.FileMode(mode64)
+	}
+
+	fh, err := os.OpenFile(address, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, mode)
+
+	if err != nil {
+		return fmt.Errorf("failed to open target file for update from cloud: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("error closing download file: %v", err)
+		}
+	}()
+
+	content := progress.NewReader(body, "downloading "+path.Base(address), file.Size)
+	_, err = io.Copy(fh, content)
+	content.Done()
+
+	if err != nil {
+		return fmt.Errorf("failed to write download content from the cloud: %v", err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the file against its detached signature
+func verifySignature(rmt remote.Remote, address string, signatureId string, keyring *signing.Keyring) error {
+	signature, err := rmt.Download(signatureId)
+
+	if err != nil {
+		return fmt.Errorf("failed to download gpg signature: %v", err)
+	}
+
+	defer func() {
+		err := signature.Close()
+
+		if err != nil {
+			log.Printf("error closing signature download: %v", err)
+		}
+	}()
+
+	fh, err := os.Open(address)
+
+	if err != nil {
+		return fmt.Errorf("failed to open file to verify: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("error closing verified file: %v", err)
+		}
+	}()
+
+	err = keyring.Verify(fh, signature)
+
+	if err != nil {
+		return fmt.Errorf("failed to verify file: %v", err)
+	}
+
+	return nil
+}
+
+// downloadVerified writes the version over address through a temp file, after checking its checksum and signature.
+// Without a keyring the signature is left unchecked
+func downloadVerified(rmt remote.Remote, address string, file *remote.Version, keyring *signing.Keyring) (string, int64, error) {
+	dirName, fileName := path.Split(address)
+
+	var renamed = false
+	tmpAddress := path.Join(dirName, "_"+fileName)
+
+	// The file may come from a directory that doesn't exist on this machine yet
+	if dirName != "" {
+		err := os.MkdirAll(dirName, 0700)
+
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to create directory for download: %v", err)
+		}
+	}
+
+	err := DownloadFile(rmt, tmpAddress, file)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer func() {
+		if !renamed {
+			err := os.Remove(tmpAddress)
+
+			if err != nil {
+				log.Printf("error removing renamed file: %v", err)
+			}
+		}
+	}()
+
+	hash, size, err := fileHash(tmpAddress)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	if file.Md5Checksum != "" && file.Md5Checksum != hash {
+		return "", 0, fmt.Errorf("downloaded checksum %s doesn't match remote %s", hash, file.Md5Checksum)
+	}
+
+	gpgFileId, gpgExists := file.Properties["gpg"]
+
+	if gpgExists && keyring == nil {
+		log.Printf("warning: %s is signed, but without a keyring its signature isn't verified", file.Id)
+	} else if gpgExists {
+		err = verifySignature(rmt, tmpAddress, gpgFileId, keyring)
+
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	_, err = os.Stat(address)
+
+	if err != nil && !os.IsNotExist(err) {
+		return "", 0, fmt.Errorf("failed to stat original file: %v", err)
+	}
+
+	err = os.Rename(tmpAddress, address)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to rename temp file to original: %v", err)
+	}
+
+	// Mark the file as renamed, this will prevent delete attempt
+	renamed = true
+
+	return hash, size, nil
+}
+
+func TmpDownloadFile(rmt remote.Remote, address string, file *remote.Version, metadataStore metadata.Store, keyring *signing.Keyring) error {
+	hash, size, err := downloadVerified(rmt, address, file, keyring)
+
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := os.Stat(address)
+
+	if err != nil {
+		return fmt.Errorf("failed to stat downloaded file: %v", address)
+	}
+
+	err = metadataStore.Set(address, metadata.FileMetadata{
+		RemoteModDate: file.ModifiedTime,
+		LocalModDate:  fileInfo.ModTime(),
+		Hash:          hash,
+		Size:          size})
+
+	if err != nil {
+		return fmt.Errorf("failed to write file metadata: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"log"
+	"os"
+	"path"
+)
+
+// checkUnchanged refuses to act on a local file that changed since the plan was made
+func checkUnchanged(action *Action) error {
+	_, err := os.Stat(action.Address)
+
+	if os.IsNotExist(err) && action.LocalHash == "" {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", action.Address, err)
+	}
+
+	hash, _, err := fileHash(action.Address)
+
+	if err != nil {
+		return err
+	}
+
+	if hash != action.LocalHash {
+		return fmt.Errorf("%s changed since the plan was made", action.Address)
+	}
+
+	return nil
+}
+
+// checkNewest refuses to transfer when the newest remote version isn't the one the plan was made against,
+// a plan applied later would otherwise overwrite a version uploaded since
+func checkNewest(rmt remote.Remote, action *Action) error {
+	versions, err := rmt.ListVersions(action.ParentId, path.Base(action.Address))
+
+	if err != nil {
+		return fmt.Errorf("unable to retrieve files: %v", err)
+	}
+
+	if len(versions) == 0 {
+		if action.Version == nil {
+			return nil
+		}
+
+		return fmt.Errorf("version %s of %s was deleted since the plan was made", action.Version.Id, action.Address)
+	}
+
+	newest := newestVersion(versions)
+
+	if action.Version == nil || newest.Id != action.Version.Id {
+		return fmt.Errorf("version %s of %s was uploaded since the plan was made", newest.Id, action.Address)
+	}
+
+	return nil
+}
+
+// checkStale refuses a transfer planned against a local file or newest remote version that changed since
+func checkStale(rmt remote.Remote, action *Action) error {
+	err := checkUnchanged(action)
+
+	if err != nil {
+		return err
+	}
+
+	return checkNewest(rmt, action)
+}
+
+// Execute applies the actions in order, stopping at the first failure or conflict
+func Execute(rmt remote.Remote, mtStore metadata.Store, keyring *signing.Keyring, actions []*Action) error {
+	return execute(rmt, mtStore, keyring, actions, false)
+}
+
+// ExecutePlan applies a saved plan like Execute, refusing transfers when the file changed on either side since
+func ExecutePlan(rmt remote.Remote, mtStore metadata.Store, keyring *signing.Keyring, actions []*Action) error {
+	return execute(rmt, mtStore, keyring, actions, true)
+}
+
+func execute(rmt remote.Remote, mtStore metadata.Store, keyring *signing.Keyring, actions []*Action, saved bool) error {
+	for _, action := range actions {
+		log.Print(action)
+
+		var err error
+
+		switch action.Type {
+		case ActionUpload:
+			if saved {
+				err = checkStale(rmt, action)
+			}
+
+			if err == nil {
+				err = UploadFile(rmt, action.Address, action.ParentId, mtStore, keyring)
+			}
+		case ActionDownload:
+			if saved {
+				err = checkStale(rmt, action)
+			}
+
+			if err == nil {
+				err = TmpDownloadFile(rmt, action.Address, action.Version, mtStore, keyring)
+			}
+		case ActionRecord:
+			err = mtStore.Set(action.Address, metadata.FileMetadata{
+				RemoteModDate: action.Version.ModifiedTime,
+				LocalModDate:  action.LocalModTime,
+				Hash:          action.LocalHash,
+				Size:          action.LocalSize})
+		case ActionSaveConflictCopy:
+			_, err = os.Stat(action.CopyAddress)
+
+			if err == nil {
+				err = fmt.Errorf("conflict copy %s already exists", action.CopyAddress)
+			} else if os.IsNotExist(err) {
+				err = DownloadFile(rmt, action.CopyAddress, action.Version)
+			}
+		case ActionConflict:
+			return &ConflictError{Address: action.Address, Remote: action.Version}
+		case ActionPurgeVersion, ActionPurgeSignature:
+			err = rmt.Delete(action.Version.Id)
+		default:
+			err = fmt.Errorf("unknown action type '%s'", action.Type)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to %s: %v", action.Type, err)
+		}
+	}
+
+	return nil
+}
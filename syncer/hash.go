@@ -0,0 +1,36 @@
+package syncer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// fileHash returns the hex md5 of the file content, the same digest drive reports as md5Checksum
+func fileHash(address string) (string, int64, error) {
+	fh, err := os.Open(address)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open file for hashing: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close hashed file: %v", err)
+		}
+	}()
+
+	hash := md5.New()
+	size, err := io.Copy(hash, fh)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash file: %v", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,141 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+type SignatureStatus string
+
+const (
+	SignatureNone    SignatureStatus = "unsigned"
+	SignatureValid   SignatureStatus = "valid"
+	SignatureInvalid SignatureStatus = "invalid"
+	// SignatureMissing is reported when the signature file is gone from the remote
+	SignatureMissing SignatureStatus = "missing"
+	// SignatureUnchecked is reported without a keyring, or when the version couldn't be downloaded for verification
+	SignatureUnchecked SignatureStatus = "unchecked"
+)
+
+// HistoryEntry is a remote version of a tracked file along with who uploaded it
+type HistoryEntry struct {
+	Version   *remote.Version
+	Host      string
+	Signature SignatureStatus
+}
+
+// checkSignature downloads the version into dir to verify it against its detached signature
+func checkSignature(rmt remote.Remote, dir string, version *remote.Version, signatureIds map[string]bool, keyring *signing.Keyring) SignatureStatus {
+	signatureId, signed := version.Properties["gpg"]
+
+	if !signed {
+		return SignatureNone
+	}
+
+	// Signatures of purged versions are deleted along with them
+	if !signatureIds[signatureId] {
+		return SignatureMissing
+	}
+
+	if keyring == nil {
+		return SignatureUnchecked
+	}
+
+	// Every version is written over the same file
+	address := path.Join(dir, "version")
+
+	err := DownloadFile(rmt, address, &remote.Version{Id: version.Id, Properties: make(map[string]string)})
+
+	if err != nil {
+		log.Printf("failed to download %s: %v", version.Id, err)
+		return SignatureUnchecked
+	}
+
+	err = verifySignature(rmt, address, signatureId, keyring)
+
+	if err != nil {
+		return SignatureInvalid
+	}
+
+	return SignatureValid
+}
+
+// History lists every remote version of a file newest first, signed versions are downloaded to verify them
+func History(rmt remote.Remote, parentId string, fileName string, keyring *signing.Keyring) ([]*HistoryEntry, error) {
+	versions, err := rmt.ListVersions(parentId, fileName)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve files: %v", err)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[j].ModifiedTime.Before(versions[i].ModifiedTime)
+	})
+
+	signatures, err := rmt.ListVersions(parentId, fileName+".sig")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gpg files: %v", err)
+	}
+
+	signatureIds := make(map[string]bool)
+
+	for _, signature := range signatures {
+		signatureIds[signature.Id] = true
+	}
+
+	dir, err := ioutil.TempDir("", "sync-log")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	defer func() {
+		err := os.RemoveAll(dir)
+
+		if err != nil {
+			log.Printf("failed to remove temp directory: %v", err)
+		}
+	}()
+
+	entries := make([]*HistoryEntry, 0, len(versions))
+
+	for _, version := range versions {
+		entries = append(entries, &HistoryEntry{
+			Version:   version,
+			Host:      version.Properties["host"],
+			Signature: checkSignature(rmt, dir, version, signatureIds, keyring)})
+	}
+
+	return entries, nil
+}
+
+// DeleteHistory deletes every remote version of a file and its signatures
+func DeleteHistory(rmt remote.Remote, parentId string, fileName string) error {
+	for _, name := range []string{fileName, fileName + ".sig"} {
+		versions, err := rmt.ListVersions(parentId, name)
+
+		if err != nil {
+			return fmt.Errorf("unable to retrieve files: %v", err)
+		}
+
+		for _, version := range versions {
+			log.Printf("deleting %s from %s", version.Id, version.ModifiedTime.UTC().Format(time.RFC3339))
+
+			err = rmt.Delete(version.Id)
+
+			if err != nil {
+				return fmt.Errorf("failed to delete file %s: %v", version.Id, err)
+			}
+		}
+	}
+
+	return nil
+}
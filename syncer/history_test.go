@@ -0,0 +1,86 @@
+package syncer
+
+import (
+	"github.com/ilyail3/fileSync/localdir"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDirectoryRemote(t *testing.T) remote.Remote {
+	rmt, err := localdir.NewDirectoryRemote(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	return rmt
+}
+
+func upload(t *testing.T, rmt remote.Remote, name string, modTime time.Time, properties map[string]string, content string) *remote.Version {
+	version, err := rmt.Upload("sync", name, modTime, properties, strings.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("failed to upload %s: %v", name, err)
+	}
+
+	return version
+}
+
+func TestHistory(t *testing.T) {
+	rmt := newTestDirectoryRemote(t)
+	machine := newTestMachine(t)
+	keyring := newTestKeyring(t)
+	now := time.Now().Truncate(time.Second)
+
+	machine.write("file.txt", "signed", now)
+	signatureId, err := signFile(rmt, machine.address("file.txt"), "sync", keyring)
+
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	valid := upload(t, rmt, "file.txt", now.Add(-4*time.Hour), map[string]string{"gpg": signatureId, "host": "laptop"}, "signed")
+	// The signature of another content doesn't match
+	invalid := upload(t, rmt, "file.txt", now.Add(-3*time.Hour), map[string]string{"gpg": signatureId, "host": "laptop"}, "tampered")
+	missing := upload(t, rmt, "file.txt", now.Add(-2*time.Hour), map[string]string{"gpg": "purged", "host": "laptop"}, "purged")
+	unsigned := upload(t, rmt, "file.txt", now.Add(-time.Hour), map[string]string{"host": "desktop"}, "unsigned")
+	upload(t, rmt, "other.txt", now, nil, "other")
+
+	cases := []struct {
+		name     string
+		keyring  *signing.Keyring
+		expected []SignatureStatus
+	}{
+		{"with keyring", keyring, []SignatureStatus{SignatureNone, SignatureMissing, SignatureInvalid, SignatureValid}},
+		{"without keyring", nil, []SignatureStatus{SignatureNone, SignatureMissing, SignatureUnchecked, SignatureUnchecked}},
+	}
+
+	for _, c := range cases {
+		entries, err := History(rmt, "sync", "file.txt", c.keyring)
+
+		if err != nil {
+			t.Fatalf("%s: failed to list history: %v", c.name, err)
+		}
+
+		if len(entries) != 4 {
+			t.Fatalf("%s: expected the 4 versions of file.txt, got %d entries", c.name, len(entries))
+		}
+
+		for i, version := range []*remote.Version{unsigned, missing, invalid, valid} {
+			if entries[i].Version.Id != version.Id {
+				t.Errorf("%s: expected entry %d to be %s, got %s", c.name, i, version.Id, entries[i].Version.Id)
+			}
+
+			if entries[i].Signature != c.expected[i] {
+				t.Errorf("%s: expected %s signature to be %s, got %s", c.name, version.Id, c.expected[i], entries[i].Signature)
+			}
+		}
+
+		if entries[0].Host != "desktop" || entries[3].Host != "laptop" {
+			t.Errorf("%s: expected the uploading hosts, got %s and %s", c.name, entries[0].Host, entries[3].Host)
+		}
+	}
+}
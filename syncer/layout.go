@@ -0,0 +1,218 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const FlatLayout = "flat"
+const TreeLayout = "tree"
+
+// RelativeFolderName holds the files inside the sync root, AbsoluteFolderName the ones outside it by their full path
+const RelativeFolderName = "relative"
+const AbsoluteFolderName = "absolute"
+
+// Layout maps a local file to the remote folder its versions are kept in, the remote name is always the file base name.
+// It's safe for concurrent use
+type Layout struct {
+	rmt      remote.Remote
+	parentId string
+	root     string
+	tree     bool
+	names    map[string][]string
+
+	// mutex is held while creating folders too, so files syncing together don't create the same folder twice
+	mutex   sync.Mutex
+	folders map[string]string
+}
+
+// NewFlatLayout keeps every file directly in the sync folder, files is every tracked file so
+// base names shared by several of them can be refused
+func NewFlatLayout(rmt remote.Remote, parentId string, files []string) *Layout {
+	names := make(map[string][]string)
+
+	for _, fullAddress := range files {
+		fileName := path.Base(fullAddress)
+		names[fileName] = append(names[fileName], fullAddress)
+	}
+
+	return &Layout{rmt: rmt, parentId: parentId, names: names}
+}
+
+// NewTreeLayout mirrors the local directories relative to root as nested remote folders
+func NewTreeLayout(rmt remote.Remote, parentId string, root string) *Layout {
+	return &Layout{rmt: rmt, parentId: parentId, root: filepath.Clean(root), tree: true, folders: make(map[string]string)}
+}
+
+// remoteDirs returns the folder names leading to the file in the tree layout
+func (l *Layout) remoteDirs(fullAddress string) ([]string, error) {
+	absAddress, err := filepath.Abs(fullAddress)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of %s: %v", fullAddress, err)
+	}
+
+	dirs := []string{RelativeFolderName}
+	relPath, err := filepath.Rel(l.root, filepath.Dir(absAddress))
+
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		dirs = []string{AbsoluteFolderName}
+		relPath = strings.TrimPrefix(filepath.Dir(absAddress), string(filepath.Separator))
+	}
+
+	for _, dirName := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if dirName != "" && dirName != "." {
+			dirs = append(dirs, dirName)
+		}
+	}
+
+	return dirs, nil
+}
+
+// Folder returns the id of the remote folder holding the versions of the file, creating it if needed
+func (l *Layout) Folder(fullAddress string) (string, error) {
+	folderId, _, err := l.resolve(fullAddress, true)
+
+	return folderId, err
+}
+
+// Lookup returns the id of the remote folder holding the versions of the file without creating anything,
+// exists is false when a folder on the way is missing
+func (l *Layout) Lookup(fullAddress string) (string, bool, error) {
+	return l.resolve(fullAddress, false)
+}
+
+func (l *Layout) resolve(fullAddress string, create bool) (string, bool, error) {
+	if !l.tree {
+		fileName := path.Base(fullAddress)
+
+		for _, other := range l.names[fileName] {
+			if other != fullAddress {
+				return "", false, fmt.Errorf(
+					"%s and %s share the name '%s' in the flat remote layout, migrate to the tree layout to sync them",
+					fullAddress, other, fileName)
+			}
+		}
+
+		return l.parentId, true, nil
+	}
+
+	dirs, err := l.remoteDirs(fullAddress)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	folderId := l.parentId
+
+	for i := range dirs {
+		key := strings.Join(dirs[:i+1], "/")
+
+		if id, ok := l.folders[key]; ok {
+			folderId = id
+			continue
+		}
+
+		if create {
+			folderId, err = l.rmt.GetOrCreateDirectory(folderId, dirs[i])
+		} else {
+			var exists bool
+			folderId, exists, err = l.rmt.FindDirectory(folderId, dirs[i])
+
+			if err == nil && !exists {
+				return "", false, nil
+			}
+		}
+
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get remote folder %s: %v", key, err)
+		}
+
+		l.folders[key] = folderId
+	}
+
+	return folderId, true, nil
+}
+
+// LayoutMarkerName is the file in the sync folder recording its layout, so every machine syncing the folder uses it
+const LayoutMarkerName = ".fileSync-layout"
+
+// ReadLayout returns the layout of the sync folder, found is false for an empty folder.
+// Folders synced before the layout was recorded are recognized by their content
+func ReadLayout(rmt remote.Remote, parentId string) (string, bool, error) {
+	markers, err := rmt.ListVersions(parentId, LayoutMarkerName)
+
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list layout marker: %v", err)
+	}
+
+	if len(markers) > 0 {
+		layoutName := newestVersion(markers).Properties["layout"]
+
+		if layoutName != FlatLayout && layoutName != TreeLayout {
+			return "", false, fmt.Errorf("sync folder records an unknown layout '%s'", layoutName)
+		}
+
+		return layoutName, true, nil
+	}
+
+	for _, folderName := range []string{RelativeFolderName, AbsoluteFolderName} {
+		_, exists, err := rmt.FindDirectory(parentId, folderName)
+
+		if err != nil {
+			return "", false, fmt.Errorf("failed to find folder %s: %v", folderName, err)
+		}
+
+		if exists {
+			return TreeLayout, true, nil
+		}
+	}
+
+	// Only backends listing whole folders can tell flat files apart, the others leave it to the caller
+	if lister, ok := rmt.(remote.FolderLister); ok {
+		versions, err := lister.ListFolder(parentId)
+
+		if err != nil {
+			return "", false, fmt.Errorf("failed to list sync folder: %v", err)
+		}
+
+		if len(versions) > 0 {
+			return FlatLayout, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// WriteLayout records the layout of the sync folder, replacing the earlier record
+func WriteLayout(rmt remote.Remote, parentId string, layoutName string) error {
+	earlier, err := rmt.ListVersions(parentId, LayoutMarkerName)
+
+	if err != nil {
+		return fmt.Errorf("failed to list layout marker: %v", err)
+	}
+
+	_, err = rmt.Upload(parentId, LayoutMarkerName, time.Now(), map[string]string{"layout": layoutName}, strings.NewReader(layoutName+"\n"))
+
+	if err != nil {
+		return fmt.Errorf("failed to upload layout marker: %v", err)
+	}
+
+	for _, version := range earlier {
+		err = rmt.Delete(version.Id)
+
+		if err != nil {
+			return fmt.Errorf("failed to delete layout marker %s: %v", version.Id, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package syncer
+
+import (
+	"github.com/ilyail3/fileSync/localdir"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupDoesNotCreateFolders(t *testing.T) {
+	root := t.TempDir()
+
+	rmt, err := localdir.NewDirectoryRemote(root)
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	layout := NewTreeLayout(rmt, "sync", "/home/user")
+
+	_, exists, err := layout.Lookup("/home/user/docs/file.txt")
+
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("expected a missing folder")
+	}
+
+	if _, err := os.Stat(path.Join(root, "sync")); !os.IsNotExist(err) {
+		t.Fatalf("expected lookup to create nothing, stat returned %v", err)
+	}
+
+	folderId, err := layout.Folder("/home/user/docs/file.txt")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	found, exists, err := NewTreeLayout(rmt, "sync", "/home/user").Lookup("/home/user/docs/file.txt")
+
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+
+	if !exists || found != folderId {
+		t.Errorf("expected lookup to find %s, got %s (exists %v)", folderId, found, exists)
+	}
+}
+
+func TestReadLayout(t *testing.T) {
+	rmt, err := localdir.NewDirectoryRemote(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	_, found, err := ReadLayout(rmt, "sync")
+
+	if err != nil {
+		t.Fatalf("failed to read layout: %v", err)
+	}
+
+	if found {
+		t.Fatalf("expected an empty sync folder to have no layout")
+	}
+
+	// A folder synced before the layout was recorded is recognized by its tree folders
+	_, err = NewTreeLayout(rmt, "sync", "/home/user").Folder("/home/user/docs/file.txt")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	layoutName, found, err := ReadLayout(rmt, "sync")
+
+	if err != nil {
+		t.Fatalf("failed to read layout: %v", err)
+	}
+
+	if !found || layoutName != TreeLayout {
+		t.Errorf("expected the %s layout, got '%s' (found %v)", TreeLayout, layoutName, found)
+	}
+
+	for _, recorded := range []string{FlatLayout, TreeLayout} {
+		err = WriteLayout(rmt, "sync", recorded)
+
+		if err != nil {
+			t.Fatalf("failed to write layout: %v", err)
+		}
+
+		layoutName, found, err = ReadLayout(rmt, "sync")
+
+		if err != nil {
+			t.Fatalf("failed to read layout: %v", err)
+		}
+
+		if !found || layoutName != recorded {
+			t.Errorf("expected the recorded %s layout, got '%s' (found %v)", recorded, layoutName, found)
+		}
+	}
+
+	markers, err := rmt.ListVersions("sync", LayoutMarkerName)
+
+	if err != nil {
+		t.Fatalf("failed to list markers: %v", err)
+	}
+
+	if len(markers) != 1 {
+		t.Errorf("expected the earlier marker to be replaced, got %d markers", len(markers))
+	}
+}
+
+func TestReadFlatLayout(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+
+	_, err := rmt.Upload(parentId, "file.txt", time.Now(), nil, strings.NewReader("content"))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	layoutName, found, err := ReadLayout(rmt, parentId)
+
+	if err != nil {
+		t.Fatalf("failed to read layout: %v", err)
+	}
+
+	if !found || layoutName != FlatLayout {
+		t.Errorf("expected files directly in the sync folder to be the %s layout, got '%s' (found %v)", FlatLayout, layoutName, found)
+	}
+}
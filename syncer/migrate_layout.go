@@ -0,0 +1,148 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"log"
+	"path"
+	"sort"
+)
+
+// copyVersion uploads the content of a version into another folder keeping its modified time and properties
+func copyVersion(rmt remote.Remote, folderId string, version *remote.Version, properties map[string]string) (*remote.Version, error) {
+	content, err := rmt.Download(version.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err := content.Close()
+
+		if err != nil {
+			log.Printf("failed to close download body: %v", err)
+		}
+	}()
+
+	copied, err := rmt.Upload(folderId, version.Name, version.ModifiedTime, properties, content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if version.Md5Checksum != "" && copied.Md5Checksum != version.Md5Checksum {
+		return nil, fmt.Errorf("copy of %s has md5 %s, expected %s", version.Id, copied.Md5Checksum, version.Md5Checksum)
+	}
+
+	return copied, nil
+}
+
+// migrateFile copies every flat version of a file and its signatures into the tree folder, then deletes the originals
+func migrateFile(rmt remote.Remote, flatId string, folderId string, fileName string) error {
+	versions, err := rmt.ListVersions(flatId, fileName)
+
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	existing, err := rmt.ListVersions(folderId, fileName)
+
+	if err != nil {
+		return err
+	}
+
+	if len(existing) > 0 {
+		return fmt.Errorf("tree folder already has %d versions of %s", len(existing), fileName)
+	}
+
+	signatures, err := rmt.ListVersions(flatId, fileName+".sig")
+
+	if err != nil {
+		return err
+	}
+
+	signatureById := make(map[string]*remote.Version)
+
+	for _, signature := range signatures {
+		signatureById[signature.Id] = signature
+	}
+
+	// Oldest first so the copies are created in the original order
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ModifiedTime.Before(versions[j].ModifiedTime)
+	})
+
+	for _, version := range versions {
+		properties := make(map[string]string)
+
+		for key, value := range version.Properties {
+			properties[key] = value
+		}
+
+		if signature, ok := signatureById[properties["gpg"]]; ok {
+			copiedSignature, err := copyVersion(rmt, folderId, signature, signature.Properties)
+
+			if err != nil {
+				return fmt.Errorf("failed to copy signature %s: %v", signature.Id, err)
+			}
+
+			properties["gpg"] = copiedSignature.Id
+		}
+
+		_, err = copyVersion(rmt, folderId, version, properties)
+
+		if err != nil {
+			return fmt.Errorf("failed to copy version %s: %v", version.Id, err)
+		}
+	}
+
+	for _, version := range append(versions, signatures...) {
+		err = rmt.Delete(version.Id)
+
+		if err != nil {
+			return fmt.Errorf("failed to delete flat version %s: %v", version.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateFlatLayout moves the history of the tracked files from the flat sync folder into the tree layout.
+// Names shared by several tracked files are left in place, their versions can't be told apart
+func MigrateFlatLayout(rmt remote.Remote, flatId string, layout *Layout, files []string) error {
+	names := make(map[string][]string)
+
+	for _, fullAddress := range files {
+		fileName := path.Base(fullAddress)
+		names[fileName] = append(names[fileName], fullAddress)
+	}
+
+	for _, fullAddress := range files {
+		fileName := path.Base(fullAddress)
+
+		if len(names[fileName]) > 1 {
+			log.Printf("not migrating %s, the flat history of '%s' is shared by %v", fullAddress, fileName, names[fileName])
+			continue
+		}
+
+		folderId, err := layout.Folder(fullAddress)
+
+		if err != nil {
+			return err
+		}
+
+		log.Printf("migrating history of %s", fullAddress)
+
+		err = migrateFile(rmt, flatId, folderId, fileName)
+
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %v", fullAddress, err)
+		}
+	}
+
+	return nil
+}
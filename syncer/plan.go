@@ -0,0 +1,250 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"os"
+	"path"
+	"time"
+)
+
+type ActionType string
+
+const (
+	// ActionUpload uploads the local file as the newest version
+	ActionUpload ActionType = "upload"
+	// ActionDownload writes Version over the local file
+	ActionDownload ActionType = "download"
+	// ActionRecord stores the sync state of a local file that already matches Version
+	ActionRecord ActionType = "record"
+	// ActionSaveConflictCopy writes Version to CopyAddress, next to the local file
+	ActionSaveConflictCopy ActionType = "save-conflict-copy"
+	// ActionConflict stops the sync of the file with a ConflictError
+	ActionConflict ActionType = "conflict"
+	// ActionPurgeVersion deletes the old Version
+	ActionPurgeVersion ActionType = "purge-version"
+	// ActionPurgeSignature deletes the signature Version
+	ActionPurgeSignature ActionType = "purge-signature"
+)
+
+// Action is a single step of a sync plan, the local fields hold the file as it was when planning
+// so a plan applied later doesn't overwrite changes made since
+type Action struct {
+	Type         ActionType      `json:"type"`
+	Address      string          `json:"address"`
+	ParentId     string          `json:"parentId"`
+	Version      *remote.Version `json:"version,omitempty"`
+	CopyAddress  string          `json:"copyAddress,omitempty"`
+	LocalHash    string          `json:"localHash,omitempty"`
+	LocalSize    int64           `json:"localSize,omitempty"`
+	LocalModTime time.Time       `json:"localModTime,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+}
+
+func (a *Action) String() string {
+	var target = ""
+
+	if a.Version != nil {
+		target = fmt.Sprintf(" version %s(%s)", a.Version.Id, a.Version.ModifiedTime.UTC().Format(time.RFC3339))
+	}
+
+	if a.CopyAddress != "" {
+		target += " to " + a.CopyAddress
+	}
+
+	if a.Reason != "" {
+		return fmt.Sprintf("%s %s%s: %s", a.Type, a.Address, target, a.Reason)
+	}
+
+	return fmt.Sprintf("%s %s%s", a.Type, a.Address, target)
+}
+
+// FileState is everything the planner decides from
+type FileState struct {
+	Address    string
+	ParentId   string
+	Versions   []*remote.Version
+	Signatures []*remote.Version
+
+	LocalExists  bool
+	LocalHash    string
+	LocalSize    int64
+	LocalModTime time.Time
+
+	SyncedExists bool
+	Synced       metadata.FileMetadata
+
+	// PendingSignatureId is the signature of an unfinished upload of the file, the upload refers to it once resumed
+	PendingSignatureId string
+}
+
+func (s *FileState) action(actionType ActionType, version *remote.Version, reason string) *Action {
+	return &Action{
+		Type:         actionType,
+		Address:      s.Address,
+		ParentId:     s.ParentId,
+		Version:      version,
+		LocalHash:    s.LocalHash,
+		LocalSize:    s.LocalSize,
+		LocalModTime: s.LocalModTime,
+		Reason:       reason}
+}
+
+// inspectLocal fills in the local file and its sync metadata
+func inspectLocal(state *FileState, mtStore metadata.Store) error {
+	var err error
+
+	state.SyncedExists, state.Synced, err = mtStore.Get(state.Address)
+
+	if err != nil {
+		return fmt.Errorf("failed to get mtstore metadata: %v", err)
+	}
+
+	fStat, err := os.Stat(state.Address)
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to get stats for file: %v", err)
+	}
+
+	if err == nil {
+		state.LocalExists = true
+		state.LocalModTime = fStat.ModTime()
+		state.LocalHash, state.LocalSize, err = fileHash(state.Address)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inspect reads the local file, its sync metadata and the remote versions in parentId
+func Inspect(rmt remote.Remote, fullAddress string, parentId string, mtStore metadata.Store) (*FileState, error) {
+	fileName := path.Base(fullAddress)
+	state := &FileState{Address: fullAddress, ParentId: parentId, Signatures: make([]*remote.Version, 0)}
+
+	versions, err := rmt.ListVersions(parentId, fileName)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve files: %v", err)
+	}
+
+	state.Versions = versions
+
+	if len(versions) > 0 {
+		state.Signatures, err = rmt.ListVersions(parentId, fileName+".sig")
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query gpg files: %v", err)
+		}
+	}
+
+	err = inspectLocal(state, mtStore)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sessions, ok := mtStore.(metadata.UploadSessionStore); ok {
+		exists, session, err := sessions.GetUploadSession(metadata.UploadSessionKey(parentId, fileName))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			state.PendingSignatureId = session.SignatureId
+		}
+	}
+
+	return state, nil
+}
+
+// InspectLocal reads the local file and its sync metadata for a file whose remote folder doesn't exist, so it has no versions
+func InspectLocal(fullAddress string, mtStore metadata.Store) (*FileState, error) {
+	state := &FileState{Address: fullAddress, Versions: make([]*remote.Version, 0), Signatures: make([]*remote.Version, 0)}
+
+	err := inspectLocal(state, mtStore)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func newestVersion(versions []*remote.Version) *remote.Version {
+	newest := versions[0]
+
+	for _, i := range versions {
+		if newest.ModifiedTime.Before(i.ModifiedTime) {
+			newest = i
+		}
+	}
+
+	return newest
+}
+
+// PlanFile decides what syncing the file takes, it only looks at the state so the same state always gives the same plan
+func PlanFile(state *FileState, policy ConflictPolicy, retention cleanup.RetentionPolicy, now time.Time) []*Action {
+	actions := make([]*Action, 0)
+
+	if len(state.Versions) == 0 {
+		if !state.LocalExists {
+			return actions
+		}
+
+		return append(actions, state.action(ActionUpload, nil, "no remote versions"))
+	}
+
+	newest := newestVersion(state.Versions)
+
+	if !state.LocalExists {
+		actions = append(actions, state.action(ActionDownload, newest, "local file missing"))
+	} else if state.LocalHash == newest.Md5Checksum {
+		if !state.SyncedExists || state.Synced.Hash != state.LocalHash || !state.Synced.RemoteModDate.Equal(newest.ModifiedTime) {
+			actions = append(actions, state.action(ActionRecord, newest, "local file matches the cloud version"))
+		}
+	} else if !state.SyncedExists {
+		// Without a last synced state there is no base to tell which side changed
+		actions = append(actions, conflictActions(state, newest, policy, "no sync state and the local file differs")...)
+	} else {
+		localChanged, remoteChanged := changedSides(state, newest)
+
+		if remoteChanged && localChanged {
+			actions = append(actions, conflictActions(state, newest, policy, "both sides changed since the last sync")...)
+		} else if remoteChanged {
+			actions = append(actions, state.action(ActionDownload, newest, "cloud version is newer"))
+		} else if localChanged {
+			actions = append(actions, state.action(ActionUpload, newest, "local file is newer"))
+		}
+	}
+
+	for _, action := range actions {
+		// Nothing is touched, old versions included, until the conflict is resolved
+		if action.Type == ActionConflict {
+			return actions
+		}
+	}
+
+	pendingSignatures := make([]string, 0)
+
+	if state.PendingSignatureId != "" {
+		pendingSignatures = append(pendingSignatures, state.PendingSignatureId)
+	}
+
+	purgeVersions, purgeSignatures := cleanup.SelectPurge(state.Versions, state.Signatures, pendingSignatures, retention, now)
+
+	for _, version := range purgeVersions {
+		actions = append(actions, state.action(ActionPurgeVersion, version, "not kept by the retention policy"))
+	}
+
+	for _, signature := range purgeSignatures {
+		actions = append(actions, state.action(ActionPurgeSignature, signature, "no kept version uses it"))
+	}
+
+	return actions
+}
@@ -0,0 +1,196 @@
+package syncer
+
+import (
+	"bytes"
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var planNow = time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+
+func planVersion(id string, age time.Duration, hash string) *remote.Version {
+	return &remote.Version{Id: id, Name: "file.txt", ModifiedTime: planNow.Add(-age), Md5Checksum: hash}
+}
+
+func actionTypes(actions []*Action) []ActionType {
+	types := make([]ActionType, 0, len(actions))
+
+	for _, action := range actions {
+		types = append(types, action.Type)
+	}
+
+	return types
+}
+
+func TestPlanFile(t *testing.T) {
+	synced := metadata.FileMetadata{RemoteModDate: planNow.Add(-2 * time.Hour), Hash: "base"}
+	base := planVersion("base", 2*time.Hour, "base")
+	newer := planVersion("newer", time.Hour, "remote")
+
+	type testCase struct {
+		name     string
+		state    FileState
+		policy   ConflictPolicy
+		expected []ActionType
+	}
+
+	cases := []testCase{
+		{
+			name:     "nothing anywhere",
+			state:    FileState{},
+			expected: []ActionType{}},
+		{
+			name:     "no versions",
+			state:    FileState{LocalExists: true, LocalHash: "local"},
+			expected: []ActionType{ActionUpload}},
+		{
+			name:     "local missing",
+			state:    FileState{Versions: []*remote.Version{base}, SyncedExists: true, Synced: synced},
+			expected: []ActionType{ActionDownload}},
+		{
+			name:     "hash match already recorded",
+			state:    FileState{Versions: []*remote.Version{base}, LocalExists: true, LocalHash: "base", SyncedExists: true, Synced: synced},
+			expected: []ActionType{}},
+		{
+			name:     "hash match without sync state",
+			state:    FileState{Versions: []*remote.Version{base}, LocalExists: true, LocalHash: "base"},
+			expected: []ActionType{ActionRecord}},
+		{
+			name:     "local changed",
+			state:    FileState{Versions: []*remote.Version{base}, LocalExists: true, LocalHash: "local", SyncedExists: true, Synced: synced},
+			expected: []ActionType{ActionUpload}},
+		{
+			name:     "remote changed",
+			state:    FileState{Versions: []*remote.Version{base, newer}, LocalExists: true, LocalHash: "base", SyncedExists: true, Synced: synced},
+			expected: []ActionType{ActionDownload}},
+	}
+
+	conflicts := map[ConflictPolicy][]ActionType{
+		Fail:       {ActionConflict},
+		KeepLocal:  {ActionUpload},
+		KeepRemote: {ActionDownload},
+		KeepBoth:   {ActionSaveConflictCopy, ActionUpload}}
+
+	for policy, expected := range conflicts {
+		cases = append(cases,
+			testCase{
+				name:     "no sync state " + string(policy),
+				state:    FileState{Versions: []*remote.Version{base}, LocalExists: true, LocalHash: "local"},
+				policy:   policy,
+				expected: expected},
+			testCase{
+				name:     "both changed " + string(policy),
+				state:    FileState{Versions: []*remote.Version{base, newer}, LocalExists: true, LocalHash: "local", SyncedExists: true, Synced: synced},
+				policy:   policy,
+				expected: expected})
+	}
+
+	for _, c := range cases {
+		c.state.Address = "/home/user/file.txt"
+		policy := c.policy
+
+		if policy == "" {
+			policy = Fail
+		}
+
+		actions := PlanFile(&c.state, policy, cleanup.DefaultRetentionPolicy, planNow)
+
+		if !reflect.DeepEqual(actionTypes(actions), c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, actionTypes(actions))
+		}
+	}
+}
+
+func TestPlanConflictSuppressesPurge(t *testing.T) {
+	synced := metadata.FileMetadata{RemoteModDate: planNow.Add(-40 * 24 * time.Hour), Hash: "old"}
+	old := planVersion("old", 40*24*time.Hour, "old")
+	signature := planVersion("old-signature", 40*24*time.Hour, "")
+	old.Properties = map[string]string{"gpg": signature.Id}
+
+	state := &FileState{
+		Address:      "/home/user/file.txt",
+		Versions:     []*remote.Version{old, planVersion("newer", time.Hour, "remote")},
+		Signatures:   []*remote.Version{signature},
+		LocalExists:  true,
+		LocalHash:    "local",
+		SyncedExists: true,
+		Synced:       synced}
+
+	retention := cleanup.RetentionPolicy{KeepLast: 1}
+
+	actions := PlanFile(state, Fail, retention, planNow)
+
+	if !reflect.DeepEqual(actionTypes(actions), []ActionType{ActionConflict}) {
+		t.Errorf("expected only the conflict, got %v", actionTypes(actions))
+	}
+
+	actions = PlanFile(state, KeepLocal, retention, planNow)
+	expected := []ActionType{ActionUpload, ActionPurgeVersion, ActionPurgeSignature}
+
+	if !reflect.DeepEqual(actionTypes(actions), expected) {
+		t.Errorf("expected %v once the conflict is resolved, got %v", expected, actionTypes(actions))
+	}
+}
+
+func TestExecuteRefusesStalePlan(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+
+	machine.write("file.txt", "local", time.Now())
+
+	state, err := Inspect(rmt, machine.address("file.txt"), parentId, machine.mtStore)
+
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+
+	actions := PlanFile(state, Fail, cleanup.DefaultRetentionPolicy, time.Now())
+
+	_, err = rmt.Upload(parentId, "file.txt", time.Now(), nil, bytes.NewReader([]byte("elsewhere")))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	err = ExecutePlan(rmt, machine.mtStore, nil, actions)
+
+	if err == nil || !strings.Contains(err.Error(), "since the plan was made") {
+		t.Fatalf("expected the stale plan to be refused, got %v", err)
+	}
+
+	if len(listVersions(t, rmt, parentId, "file.txt")) != 1 {
+		t.Errorf("expected the version uploaded elsewhere to stay the only one")
+	}
+}
+
+func TestExecuteDoesNotCheckFreshPlan(t *testing.T) {
+	server, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+
+	machine.write("file.txt", "local", time.Now())
+
+	state, err := Inspect(rmt, machine.address("file.txt"), parentId, machine.mtStore)
+
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+
+	actions := PlanFile(state, Fail, cleanup.DefaultRetentionPolicy, time.Now())
+	requests := server.Requests()
+
+	err = Execute(rmt, machine.mtStore, nil, actions)
+
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// Only a saved plan is checked against the remote again, a fresh one just uploads
+	if server.Requests()-requests != 1 {
+		t.Errorf("expected the upload to be the only request, got %d", server.Requests()-requests)
+	}
+}
@@ -0,0 +1,71 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"log"
+	"time"
+)
+
+// FindVersion picks a version by its id, or by its modified time in RFC3339 or 20060102T150405Z form
+func FindVersion(versions []*remote.Version, selector string) (*remote.Version, error) {
+	for _, version := range versions {
+		if version.Id == selector {
+			return version, nil
+		}
+	}
+
+	mTime, err := time.Parse(time.RFC3339, selector)
+
+	if err != nil {
+		mTime, err = time.Parse("20060102T150405Z", selector)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("no version with id '%s'", selector)
+	}
+
+	var found *remote.Version
+
+	for _, version := range versions {
+		if version.ModifiedTime.Truncate(time.Second).Equal(mTime.Truncate(time.Second)) {
+			if found != nil {
+				return nil, fmt.Errorf("versions %s and %s were both modified at %s, select one by id", found.Id, version.Id, selector)
+			}
+
+			found = version
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no version modified at %s", selector)
+	}
+
+	return found, nil
+}
+
+// RestoreVersion writes an older version over the local file, verified the same way as TmpDownloadFile.
+// The sync metadata is kept so the next sync uploads the restored content, reupload does it right away
+func RestoreVersion(rmt remote.Remote, fullAddress string, parentId string, version *remote.Version, mtStore metadata.Store, keyring *signing.Keyring, reupload bool) error {
+	log.Printf("restoring %s to version %s from %s", fullAddress, version.Id, version.ModifiedTime.UTC().Format(time.RFC3339))
+
+	_, _, err := downloadVerified(rmt, fullAddress, version, keyring)
+
+	if err != nil {
+		return fmt.Errorf("failed to download version %s: %v", version.Id, err)
+	}
+
+	if !reupload {
+		return nil
+	}
+
+	err = UploadFile(rmt, fullAddress, parentId, mtStore, keyring)
+
+	if err != nil {
+		return fmt.Errorf("failed to upload restored file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package syncer
+
+import (
+	"github.com/ilyail3/fileSync/remote"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindVersion(t *testing.T) {
+	modTime := time.Date(2020, 5, 17, 10, 30, 15, 0, time.UTC)
+
+	versions := []*remote.Version{
+		{Id: "first", ModifiedTime: modTime},
+		{Id: "second", ModifiedTime: modTime.Add(time.Hour).Add(400 * time.Millisecond)},
+		{Id: "third", ModifiedTime: modTime.Add(2 * time.Hour)},
+		{Id: "fourth", ModifiedTime: modTime.Add(2 * time.Hour).Add(600 * time.Millisecond)}}
+
+	cases := []struct {
+		selector string
+		expected string
+	}{
+		{"first", "first"},
+		{"second", "second"},
+		{"2020-05-17T10:30:15Z", "first"},
+		{"2020-05-17T12:30:15+01:00", "second"},
+		{"20200517T113015Z", "second"},
+	}
+
+	for _, c := range cases {
+		version, err := FindVersion(versions, c.selector)
+
+		if err != nil {
+			t.Errorf("%s: %v", c.selector, err)
+			continue
+		}
+
+		if version.Id != c.expected {
+			t.Errorf("%s: expected version %s, got %s", c.selector, c.expected, version.Id)
+		}
+	}
+
+	failures := []struct {
+		selector string
+		message  string
+	}{
+		{"unknown", "no version with id"},
+		{"2020-05-17T10:30:16Z", "no version modified at"},
+		{"20200517T103016Z", "no version modified at"},
+		// Versions within the same second can only be told apart by id
+		{"2020-05-17T12:30:15Z", "select one by id"},
+	}
+
+	for _, c := range failures {
+		version, err := FindVersion(versions, c.selector)
+
+		if err == nil {
+			t.Errorf("%s: expected no version, got %s", c.selector, version.Id)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), c.message) {
+			t.Errorf("%s: expected an error about '%s', got %v", c.selector, c.message, err)
+		}
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	rmt := newTestDirectoryRemote(t)
+	machine := newTestMachine(t)
+	now := time.Now().Truncate(time.Second)
+
+	first := upload(t, rmt, "file.txt", now.Add(-2*time.Hour), nil, "first")
+	upload(t, rmt, "file.txt", now.Add(-time.Hour), nil, "second")
+	machine.write("file.txt", "second", now.Add(-time.Hour))
+
+	err := RestoreVersion(rmt, machine.address("file.txt"), "sync", first, machine.mtStore, nil, false)
+
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if machine.read("file.txt") != "first" {
+		t.Errorf("expected the restored content, got '%s'", machine.read("file.txt"))
+	}
+
+	if versions := listVersions(t, rmt, "sync", "file.txt"); len(versions) != 2 {
+		t.Errorf("expected a restore not to upload a new version, got %d versions", len(versions))
+	}
+
+	files, err := ioutil.ReadDir(machine.dir)
+
+	if err != nil {
+		t.Fatalf("failed to list machine folder: %v", err)
+	}
+
+	for _, file := range files {
+		if file.Name() != "file.txt" && file.Name() != "db" {
+			t.Errorf("expected the download temp file to be gone, found %s", file.Name())
+		}
+	}
+
+	// Versions are stamped with the upload time in seconds, the next one has to land in a later second
+	time.Sleep(1100 * time.Millisecond)
+
+	err = RestoreVersion(rmt, machine.address("file.txt"), "sync", first, machine.mtStore, nil, true)
+
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	versions := listVersions(t, rmt, "sync", "file.txt")
+
+	if len(versions) != 3 {
+		t.Fatalf("expected reupload to add a version, got %d versions", len(versions))
+	}
+
+	body, err := rmt.Download(newestVersion(versions).Id)
+
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(body)
+
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if string(content) != "first" {
+		t.Errorf("expected the newest version to hold the restored content, got '%s'", content)
+	}
+}
+
+func TestRestoreRefusesInvalidSignature(t *testing.T) {
+	rmt := newTestDirectoryRemote(t)
+	machine := newTestMachine(t)
+	keyring := newTestKeyring(t)
+	now := time.Now().Truncate(time.Second)
+
+	machine.write("file.txt", "signed", now)
+	signatureId, err := signFile(rmt, machine.address("file.txt"), "sync", keyring)
+
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	tampered := upload(t, rmt, "file.txt", now.Add(-time.Hour), map[string]string{"gpg": signatureId}, "tampered")
+
+	err = RestoreVersion(rmt, machine.address("file.txt"), "sync", tampered, machine.mtStore, keyring, false)
+
+	if err == nil {
+		t.Fatalf("expected a version not matching its signature to be refused")
+	}
+
+	if machine.read("file.txt") != "signed" {
+		t.Errorf("expected the local file to be left alone, got '%s'", machine.read("file.txt"))
+	}
+}
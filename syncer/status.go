@@ -0,0 +1,144 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"path"
+	"time"
+)
+
+type FileStatus string
+
+const (
+	// StatusClean is a local file matching the newest version
+	StatusClean FileStatus = "clean"
+	// StatusLocalModified is a local file changed since the last sync
+	StatusLocalModified FileStatus = "local-modified"
+	// StatusRemoteNewer is a newer version uploaded since the last sync
+	StatusRemoteNewer FileStatus = "remote-newer"
+	// StatusConflict is both sides changed, or no sync state to tell which one did
+	StatusConflict FileStatus = "conflict"
+	// StatusLocalMissing is a synced file removed locally
+	StatusLocalMissing FileStatus = "local-missing"
+	// StatusRemoteMissing is a local file without any version uploaded
+	StatusRemoteMissing FileStatus = "remote-missing"
+)
+
+// changedSides tells which side changed since the last sync, the state must have sync metadata
+func changedSides(state *FileState, newest *remote.Version) (localChanged bool, remoteChanged bool) {
+	synced := state.Synced
+
+	if synced.Hash == "" {
+		// Rows written before content hashes were stored only have the dates
+		remoteChanged = synced.RemoteModDate.Before(newest.ModifiedTime)
+		localChanged = state.LocalModTime.Truncate(time.Second).After(synced.LocalModDate)
+
+		return localChanged, remoteChanged
+	}
+
+	if newest.Md5Checksum != "" {
+		remoteChanged = newest.Md5Checksum != synced.Hash
+	} else {
+		remoteChanged = synced.RemoteModDate.Before(newest.ModifiedTime)
+	}
+
+	return state.LocalHash != synced.Hash, remoteChanged
+}
+
+// Status sums up the state the same way PlanFile reads it, clean is the only status sync has nothing to do for
+func Status(state *FileState) FileStatus {
+	if !state.LocalExists {
+		return StatusLocalMissing
+	}
+
+	if len(state.Versions) == 0 {
+		return StatusRemoteMissing
+	}
+
+	newest := newestVersion(state.Versions)
+
+	if state.LocalHash == newest.Md5Checksum {
+		return StatusClean
+	}
+
+	if !state.SyncedExists {
+		return StatusConflict
+	}
+
+	localChanged, remoteChanged := changedSides(state, newest)
+
+	switch {
+	case localChanged && remoteChanged:
+		return StatusConflict
+	case remoteChanged:
+		return StatusRemoteNewer
+	case localChanged:
+		return StatusLocalModified
+	}
+
+	return StatusClean
+}
+
+// InspectFolder inspects files sharing the remote folder parentId, listing the folder once
+// when the backend supports it instead of querying every file on its own
+func InspectFolder(rmt remote.Remote, parentId string, fullAddresses []string, mtStore metadata.Store) ([]*FileState, error) {
+	lister, ok := rmt.(remote.FolderLister)
+
+	if !ok {
+		states := make([]*FileState, 0, len(fullAddresses))
+
+		for _, fullAddress := range fullAddresses {
+			state, err := Inspect(rmt, fullAddress, parentId, mtStore)
+
+			if err != nil {
+				return nil, err
+			}
+
+			states = append(states, state)
+		}
+
+		return states, nil
+	}
+
+	folderVersions, err := lister.ListFolder(parentId)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve files: %v", err)
+	}
+
+	byName := make(map[string][]*remote.Version)
+
+	for _, version := range folderVersions {
+		byName[version.Name] = append(byName[version.Name], version)
+	}
+
+	states := make([]*FileState, 0, len(fullAddresses))
+
+	for _, fullAddress := range fullAddresses {
+		fileName := path.Base(fullAddress)
+		state := &FileState{
+			Address:    fullAddress,
+			ParentId:   parentId,
+			Versions:   make([]*remote.Version, 0),
+			Signatures: make([]*remote.Version, 0)}
+
+		if versions, ok := byName[fileName]; ok {
+			state.Versions = versions
+		}
+
+		if signatures, ok := byName[fileName+".sig"]; ok {
+			state.Signatures = signatures
+		}
+
+		err = inspectLocal(state, mtStore)
+
+		if err != nil {
+			return nil, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,42 @@
+package syncer
+
+import (
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"log"
+	"time"
+)
+
+// SyncFile plans the sync of a single file and executes the plan, dryRun only logs it
+func SyncFile(fullAddress string, parentId string, rmt remote.Remote, mtStore metadata.Store, keyring *signing.Keyring, policy ConflictPolicy, retention cleanup.RetentionPolicy, dryRun bool) error {
+	log.Printf("querying remote for file: %s", fullAddress)
+
+	state, err := Inspect(rmt, fullAddress, parentId, mtStore)
+
+	if err != nil {
+		return err
+	}
+
+	actions := PlanFile(state, policy, retention, time.Now())
+
+	if !dryRun {
+		return Execute(rmt, mtStore, keyring, actions)
+	}
+
+	LogDryRun(fullAddress, actions)
+
+	return nil
+}
+
+// LogDryRun logs what executing the plan of the file would do
+func LogDryRun(fullAddress string, actions []*Action) {
+	if len(actions) == 0 {
+		log.Printf("dry run: %s is up to date", fullAddress)
+	}
+
+	for _, action := range actions {
+		log.Printf("dry run: would %s", action)
+	}
+}
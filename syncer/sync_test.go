@@ -0,0 +1,258 @@
+package syncer
+
+import (
+	"bytes"
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/gdrive"
+	"github.com/ilyail3/fileSync/gdrive/fakedrive"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/retry"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// testMachine is a local folder and metadata store syncing against the shared fake drive
+type testMachine struct {
+	t       *testing.T
+	dir     string
+	mtStore *metadata.SqliteMetadataStore
+}
+
+func newTestRemote(t *testing.T) (*fakedrive.Server, remote.Remote, string) {
+	server := fakedrive.NewServer()
+	t.Cleanup(server.Close)
+
+	srv, err := server.Service()
+
+	if err != nil {
+		t.Fatalf("failed to create drive client: %v", err)
+	}
+
+	rmt := gdrive.NewDriveRemote(srv, retry.Policy{}, nil)
+
+	parentId, err := rmt.GetOrCreateDirectory("", "sync")
+
+	if err != nil {
+		t.Fatalf("failed to create sync folder: %v", err)
+	}
+
+	return server, rmt, parentId
+}
+
+func newTestMachine(t *testing.T) *testMachine {
+	dir := t.TempDir()
+
+	err := os.Mkdir(path.Join(dir, "db"), 0700)
+
+	if err != nil {
+		t.Fatalf("failed to create store folder: %v", err)
+	}
+
+	mtStore, err := metadata.NewSQLite3Store(path.Join(dir, "db"))
+
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err := mtStore.Close()
+
+		if err != nil {
+			t.Errorf("failed to close store: %v", err)
+		}
+	})
+
+	return &testMachine{t: t, dir: dir, mtStore: mtStore}
+}
+
+func (m *testMachine) address(name string) string {
+	return path.Join(m.dir, name)
+}
+
+func (m *testMachine) write(name string, content string, modTime time.Time) {
+	err := ioutil.WriteFile(m.address(name), []byte(content), 0640)
+
+	if err != nil {
+		m.t.Fatalf("failed to write %s: %v", name, err)
+	}
+
+	err = os.Chtimes(m.address(name), modTime, modTime)
+
+	if err != nil {
+		m.t.Fatalf("failed to set times of %s: %v", name, err)
+	}
+}
+
+func (m *testMachine) read(name string) string {
+	content, err := ioutil.ReadFile(m.address(name))
+
+	if err != nil {
+		m.t.Fatalf("failed to read %s: %v", name, err)
+	}
+
+	return string(content)
+}
+
+func (m *testMachine) sync(rmt remote.Remote, parentId string, name string, retention cleanup.RetentionPolicy) {
+	err := SyncFile(m.address(name), parentId, rmt, m.mtStore, nil, Fail, retention, false)
+
+	if err != nil {
+		m.t.Fatalf("failed to sync %s: %v", name, err)
+	}
+}
+
+func listVersions(t *testing.T, rmt remote.Remote, parentId string, name string) []*remote.Version {
+	versions, err := rmt.ListVersions(parentId, name)
+
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", name, err)
+	}
+
+	return versions
+}
+
+func TestSyncFirstUpload(t *testing.T) {
+	server, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+
+	machine.write("file.txt", "first", time.Now().Add(-time.Hour))
+	machine.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	versions := listVersions(t, rmt, parentId, "file.txt")
+
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(versions))
+	}
+
+	content, _ := server.Content(versions[0].Id)
+
+	if !bytes.Equal(content, []byte("first")) {
+		t.Errorf("expected uploaded content 'first', got '%s'", content)
+	}
+
+	exists, synced, err := machine.mtStore.Get(machine.address("file.txt"))
+
+	if err != nil {
+		t.Fatalf("failed to read sync state: %v", err)
+	}
+
+	if !exists || synced.Hash != versions[0].Md5Checksum || !synced.RemoteModDate.Equal(versions[0].ModifiedTime) {
+		t.Errorf("expected the uploaded version to be recorded, got %v %+v", exists, synced)
+	}
+}
+
+func TestSyncUnchangedIsNoOp(t *testing.T) {
+	server, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+
+	machine.write("file.txt", "first", time.Now().Add(-time.Hour))
+	machine.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	files := len(server.Files())
+
+	machine.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	if len(server.Files()) != files {
+		t.Errorf("expected no new remote files, got %d instead of %d", len(server.Files()), files)
+	}
+
+	if len(listVersions(t, rmt, parentId, "file.txt")) != 1 {
+		t.Errorf("expected the single version to stay")
+	}
+}
+
+func TestSyncDownloadsNewerRemote(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	first := newTestMachine(t)
+	second := newTestMachine(t)
+	modTime := time.Now().Add(-2 * time.Hour)
+
+	first.write("file.txt", "first", modTime)
+	first.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+	second.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	if second.read("file.txt") != "first" {
+		t.Fatalf("expected the second machine to download the file")
+	}
+
+	// Versions are stamped with the upload time in seconds, the next one has to land in a later second
+	time.Sleep(1100 * time.Millisecond)
+
+	second.write("file.txt", "second", modTime.Add(time.Hour))
+	second.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+	first.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	if first.read("file.txt") != "second" {
+		t.Errorf("expected the newer cloud version to be downloaded, got '%s'", first.read("file.txt"))
+	}
+
+	if len(listVersions(t, rmt, parentId, "file.txt")) != 2 {
+		t.Errorf("expected 2 versions")
+	}
+}
+
+func TestSyncRestoresMissingLocalFile(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+
+	machine.write("file.txt", "first", time.Now().Add(-time.Hour))
+	machine.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	err := os.Remove(machine.address("file.txt"))
+
+	if err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	machine.sync(rmt, parentId, "file.txt", cleanup.DefaultRetentionPolicy)
+
+	if machine.read("file.txt") != "first" {
+		t.Errorf("expected the missing file to be downloaded again")
+	}
+
+	if len(listVersions(t, rmt, parentId, "file.txt")) != 1 {
+		t.Errorf("expected no new version for a missing file")
+	}
+}
+
+func TestSyncPurgesOldVersionsAndSignatures(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	machine := newTestMachine(t)
+	now := time.Now().Truncate(time.Second)
+	var newest *remote.Version
+
+	for i, age := range []time.Duration{30 * 24 * time.Hour, 20 * 24 * time.Hour, time.Hour} {
+		content := string(rune('a' + i))
+
+		signature, err := rmt.Upload(parentId, "file.txt.sig", now.Add(-age), nil, bytes.NewReader([]byte("sig "+content)))
+
+		if err != nil {
+			t.Fatalf("failed to upload signature: %v", err)
+		}
+
+		newest, err = rmt.Upload(parentId, "file.txt", now.Add(-age), map[string]string{"gpg": signature.Id}, bytes.NewReader([]byte(content)))
+
+		if err != nil {
+			t.Fatalf("failed to upload version: %v", err)
+		}
+	}
+
+	machine.write("file.txt", "c", now.Add(-time.Hour))
+	machine.sync(rmt, parentId, "file.txt", cleanup.RetentionPolicy{KeepWithin: 24 * time.Hour})
+
+	versions := listVersions(t, rmt, parentId, "file.txt")
+
+	if len(versions) != 1 || versions[0].Id != newest.Id {
+		t.Fatalf("expected only the newest version to be kept, got %d versions", len(versions))
+	}
+
+	signatures := listVersions(t, rmt, parentId, "file.txt.sig")
+
+	if len(signatures) != 1 || signatures[0].Id != newest.Properties["gpg"] {
+		t.Errorf("expected only the signature of the newest version to be kept, got %d signatures", len(signatures))
+	}
+}
@@ -0,0 +1,157 @@
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ilyail3/fileSync/metadata"
+	"github.com/ilyail3/fileSync/progress"
+	"github.com/ilyail3/fileSync/remote"
+	"github.com/ilyail3/fileSync/signing"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// pendingSignature returns the signature of an unfinished upload of the same content, resuming it keeps the
+// metadata it was started with so it has to keep referring to that signature
+func pendingSignature(metadataStore metadata.Store, parentId string, address string, hash string, size int64) (string, error) {
+	sessions, ok := metadataStore.(metadata.UploadSessionStore)
+
+	if !ok {
+		return "", nil
+	}
+
+	exists, session, err := sessions.GetUploadSession(metadata.UploadSessionKey(parentId, path.Base(address)))
+
+	if err != nil {
+		return "", err
+	}
+
+	if !exists || session.Hash != hash || session.Size != size {
+		return "", nil
+	}
+
+	return session.SignatureId, nil
+}
+
+// signFile uploads the detached signature of the file, returning its id
+func signFile(rmt remote.Remote, address string, parentId string, keyring *signing.Keyring) (string, error) {
+	fh, err := os.Open(address)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to open file to sign: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("failed to close signed file: %v", err)
+		}
+	}()
+
+	var signature bytes.Buffer
+
+	err = keyring.Sign(&signature, fh)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to sign file: %v", err)
+	}
+
+	resultFile, err := rmt.Upload(parentId, path.Base(address)+".sig", time.Now(), make(map[string]string), bytes.NewReader(signature.Bytes()))
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upload signature file: %v", err)
+	}
+
+	return resultFile.Id, nil
+}
+
+func UploadFile(rmt remote.Remote, address string, parentId string, metadataStore metadata.Store, keyring *signing.Keyring) error {
+	stats, err := os.Stat(address)
+
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	hash, size, err := fileHash(address)
+
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Open(address)
+	// modTime := time.Now().Format(time.RFC3339)
+
+	if err != nil {
+		return fmt.Errorf("failed to open file for uploading: %v", err)
+	}
+
+	defer func() {
+		err := fh.Close()
+
+		if err != nil {
+			log.Printf("faiiled to close uploaded file: %v", err)
+		}
+	}()
+
+	properties := make(map[string]string)
+
+	properties["mode"] = fmt.Sprintf("%d", stats.Mode())
+
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %v", err)
+	}
+
+	properties["host"] = hostname
+
+	// sign
+	if keyring.CanSign() {
+		signatureFileId, err := pendingSignature(metadataStore, parentId, address, hash, size)
+
+		if err != nil {
+			return fmt.Errorf("failed to read upload session: %v", err)
+		}
+
+		if signatureFileId == "" {
+			signatureFileId, err = signFile(rmt, address, parentId, keyring)
+
+			if err != nil {
+				return fmt.Errorf("failed to sign file: %v", err)
+			}
+		}
+
+		properties["gpg"] = signatureFileId
+	}
+
+	log.Printf("properties: %v", properties)
+
+	modTime := time.Now()
+
+	content := progress.NewReader(fh, "uploading "+path.Base(address), size)
+	uploaded, err := rmt.Upload(parentId, path.Base(address), modTime, properties, content)
+	content.Done()
+
+	if err != nil {
+		return fmt.Errorf("upload operation failed: %v", err)
+	}
+
+	if uploaded.Md5Checksum != "" && uploaded.Md5Checksum != hash {
+		return fmt.Errorf("uploaded checksum %s doesn't match local %s, file changed during upload", uploaded.Md5Checksum, hash)
+	}
+
+	err = metadataStore.Set(address, metadata.FileMetadata{
+		RemoteModDate: uploaded.ModifiedTime,
+		LocalModDate:  stats.ModTime(),
+		Hash:          hash,
+		Size:          size})
+
+	if err != nil {
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"github.com/ilyail3/fileSync/cleanup"
+	"github.com/ilyail3/fileSync/gdrive"
+	"github.com/ilyail3/fileSync/gdrive/fakedrive"
+	"github.com/ilyail3/fileSync/retry"
+	"github.com/ilyail3/fileSync/signing"
+	"github.com/ilyail3/fileSync/signing/signingtest"
+	"io"
+	"testing"
+	"time"
+)
+
+// interruptedReader lets the hashing pass read everything, then fails the upload pass at limit.
+// The reader isn't embedded, its WriteTo would bypass Read
+type interruptedReader struct {
+	reader *bytes.Reader
+	limit  int64
+	hashed bool
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if !r.hashed {
+		n, err := r.reader.Read(p)
+		r.hashed = err == io.EOF
+
+		return n, err
+	}
+
+	position, _ := r.reader.Seek(0, io.SeekCurrent)
+
+	if position >= r.limit {
+		return 0, errors.New("connection dropped")
+	}
+
+	if position+int64(len(p)) > r.limit {
+		p = p[:r.limit-position]
+	}
+
+	return r.reader.Read(p)
+}
+
+func (r *interruptedReader) Seek(offset int64, whence int) (int64, error) {
+	return r.reader.Seek(offset, whence)
+}
+
+func newTestKeyring(t *testing.T) *signing.Keyring {
+	keyringFile, fingerprint := signingtest.WriteKeyring(t, nil)
+
+	keyring, err := signing.NewKeyring(keyringFile, fingerprint, nil)
+
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+
+	return keyring
+}
+
+func TestResumedUploadKeepsItsSignature(t *testing.T) {
+	server := fakedrive.NewServer()
+	t.Cleanup(server.Close)
+
+	srv, err := server.Service()
+
+	if err != nil {
+		t.Fatalf("failed to create drive client: %v", err)
+	}
+
+	machine := newTestMachine(t)
+	keyring := newTestKeyring(t)
+	uploads, err := gdrive.NewResumableUploads(server.Client(), srv, machine.mtStore, gdrive.MinChunkSize)
+
+	if err != nil {
+		t.Fatalf("failed to set up resumable uploads: %v", err)
+	}
+
+	rmt := gdrive.NewDriveRemote(srv, retry.Policy{}, uploads)
+	parentId, err := rmt.GetOrCreateDirectory("", "sync")
+
+	if err != nil {
+		t.Fatalf("failed to create sync folder: %v", err)
+	}
+
+	retention := cleanup.RetentionPolicy{KeepLast: 1}
+
+	machine.write("big.bin", "first", time.Now().Add(-time.Hour))
+
+	err = SyncFile(machine.address("big.bin"), parentId, rmt, machine.mtStore, keyring, Fail, retention, false)
+
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	// Versions are stamped with the upload time in seconds, the next one has to land in a later second
+	time.Sleep(1100 * time.Millisecond)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*gdrive.MinChunkSize/16+1)
+	machine.write("big.bin", string(content), time.Now())
+
+	// An earlier run signed the new content and was stopped after the first chunk
+	signatureId, err := signFile(rmt, machine.address("big.bin"), parentId, keyring)
+
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	reader := &interruptedReader{reader: bytes.NewReader(content), limit: gdrive.MinChunkSize + 10}
+	_, err = rmt.Upload(parentId, "big.bin", time.Now(), map[string]string{"gpg": signatureId}, reader)
+
+	if err == nil {
+		t.Fatalf("expected the interrupted upload to fail")
+	}
+
+	err = SyncFile(machine.address("big.bin"), parentId, rmt, machine.mtStore, keyring, Fail, retention, false)
+
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+
+	if server.UploadSessions() != 1 {
+		t.Errorf("expected the upload to resume, %d sessions were started", server.UploadSessions())
+	}
+
+	versions, err := rmt.ListVersions(parentId, "big.bin")
+
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+
+	newest := newestVersion(versions)
+
+	if newest.Properties["gpg"] != signatureId {
+		t.Errorf("expected the resumed upload to refer to signature %s, got %s", signatureId, newest.Properties["gpg"])
+	}
+
+	signatures, err := rmt.ListVersions(parentId, "big.bin.sig")
+
+	if err != nil {
+		t.Fatalf("failed to list signatures: %v", err)
+	}
+
+	var found = false
+
+	for _, signature := range signatures {
+		found = found || signature.Id == signatureId
+	}
+
+	if !found || len(signatures) != 2 {
+		t.Fatalf("expected the pending signature to be kept and not signed again, got %d signatures", len(signatures))
+	}
+
+	other := newTestMachine(t)
+
+	err = SyncFile(other.address("big.bin"), parentId, rmt, other.mtStore, keyring, Fail, retention, false)
+
+	if err != nil {
+		t.Fatalf("failed to download the resumed upload: %v", err)
+	}
+
+	if other.read("big.bin") != string(content) {
+		t.Errorf("downloaded content doesn't match")
+	}
+}
+
+func TestSignedVersionDownloadsWithoutKeyring(t *testing.T) {
+	_, rmt, parentId := newTestRemote(t)
+	first := newTestMachine(t)
+	second := newTestMachine(t)
+	retention := cleanup.DefaultRetentionPolicy
+
+	first.write("file.txt", "signed", time.Now().Add(-time.Hour))
+
+	err := SyncFile(first.address("file.txt"), parentId, rmt, first.mtStore, newTestKeyring(t), Fail, retention, false)
+
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	if _, signed := listVersions(t, rmt, parentId, "file.txt")[0].Properties["gpg"]; !signed {
+		t.Fatalf("expected the uploaded version to be signed")
+	}
+
+	// The signature can't be checked, the version is still downloaded
+	err = SyncFile(second.address("file.txt"), parentId, rmt, second.mtStore, nil, Fail, retention, false)
+
+	if err != nil {
+		t.Fatalf("failed to download without a keyring: %v", err)
+	}
+
+	if second.read("file.txt") != "signed" {
+		t.Errorf("expected the signed version to be downloaded, got '%s'", second.read("file.txt"))
+	}
+}
@@ -0,0 +1,52 @@
+package syncer
+
+import (
+	"fmt"
+	"github.com/ilyail3/fileSync/ignore"
+	"os"
+	"path/filepath"
+)
+
+// ListDirectoryFiles returns every regular file under root that isn't excluded by a .syncignore file
+func ListDirectoryFiles(root string) ([]string, error) {
+	files := make([]string, 0)
+	matcher := ignore.NewMatcher()
+
+	err := filepath.Walk(root, func(address string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, address)
+
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if relPath == "." {
+				relPath = ""
+			} else if matcher.Match(relPath, true) {
+				return filepath.SkipDir
+			}
+
+			return matcher.AddFile(root, relPath)
+		}
+
+		if !info.Mode().IsRegular() || matcher.Match(relPath, false) {
+			return nil
+		}
+
+		files = append(files, address)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %v", root, err)
+	}
+
+	return files, nil
+}
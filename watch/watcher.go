@@ -0,0 +1,266 @@
+package watch
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/ilyail3/fileSync/ignore"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDelay is how long a file has to stay untouched before it's synced
+const DefaultDelay = 2 * time.Second
+
+// Watcher reports tracked files once a burst of writes to them settles. The directories are watched
+// rather than the files, editors saving through a rename replace the inode a file watch would hold.
+// Files and directories created inside a tracked directory are picked up unless a .syncignore excludes them
+type Watcher struct {
+	fsWatcher *fsnotify.Watcher
+	files     map[string]bool
+	roots     []string
+	delay     time.Duration
+	changed   chan string
+	done      chan struct{}
+
+	pollInterval time.Duration
+	onPoll       func()
+
+	mutex  sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// NewWatcher watches files, and every directory of the tracked directories roots for new files
+func NewWatcher(files []string, roots []string, delay time.Duration) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filesystem watcher: %v", err)
+	}
+
+	w := &Watcher{
+		fsWatcher: fsWatcher,
+		files:     make(map[string]bool),
+		delay:     delay,
+		changed:   make(chan string),
+		done:      make(chan struct{}),
+		timers:    make(map[string]*time.Timer)}
+
+	dirs := make(map[string]bool)
+
+	for _, fullAddress := range files {
+		w.files[filepath.Clean(fullAddress)] = true
+		dirs[filepath.Dir(fullAddress)] = true
+	}
+
+	for dirName := range dirs {
+		err = fsWatcher.Add(dirName)
+
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to watch directory %s: %v", dirName, err)
+		}
+	}
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		w.roots = append(w.roots, root)
+
+		err = w.addTree(root, root, false)
+
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// rootOf returns the tracked directory holding fullAddress, or false when it's outside all of them
+func (w *Watcher) rootOf(fullAddress string) (string, bool) {
+	for _, root := range w.roots {
+		if strings.HasPrefix(fullAddress, root+string(filepath.Separator)) {
+			return root, true
+		}
+	}
+
+	return "", false
+}
+
+// addTree watches dirName and every directory below it that isn't ignored, with report set the
+// files already in it are added and reported, they were created before the watch could see them
+func (w *Watcher) addTree(root string, dirName string, report bool) error {
+	m := ignore.NewMatcher()
+
+	if dirName != root {
+		var err error
+		m, err = ignore.LoadMatcher(root, filepath.Dir(dirName))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := filepath.Walk(dirName, func(address string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, address)
+
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if relPath == "." {
+				relPath = ""
+			} else if m.Match(relPath, true) {
+				return filepath.SkipDir
+			}
+
+			err = m.AddFile(root, relPath)
+
+			if err != nil {
+				return err
+			}
+
+			return w.fsWatcher.Add(address)
+		}
+
+		if report && info.Mode().IsRegular() && !m.Match(relPath, false) && !w.files[address] {
+			w.files[address] = true
+			w.touch(address)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to watch directory %s: %v", dirName, err)
+	}
+
+	return nil
+}
+
+// created picks up a file or directory created inside a tracked directory
+func (w *Watcher) created(fullAddress string) error {
+	root, ok := w.rootOf(fullAddress)
+
+	if !ok || w.files[fullAddress] {
+		return nil
+	}
+
+	info, err := os.Lstat(fullAddress)
+
+	if os.IsNotExist(err) {
+		// Already gone again, like the temporary file of an editor
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", fullAddress, err)
+	}
+
+	if info.IsDir() {
+		return w.addTree(root, fullAddress, true)
+	}
+
+	excluded, err := ignore.Excluded(root, fullAddress)
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().IsRegular() && !excluded {
+		w.files[fullAddress] = true
+	}
+
+	return nil
+}
+
+// touch restarts the quiet period of the file
+func (w *Watcher) touch(fullAddress string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if timer, ok := w.timers[fullAddress]; ok {
+		timer.Reset(w.delay)
+		return
+	}
+
+	w.timers[fullAddress] = time.AfterFunc(w.delay, func() {
+		w.mutex.Lock()
+		delete(w.timers, fullAddress)
+		w.mutex.Unlock()
+
+		select {
+		case w.changed <- fullAddress:
+		case <-w.done:
+		}
+	})
+}
+
+// Poll makes Run also call onPoll every interval, never at the same time as onChange
+func (w *Watcher) Poll(interval time.Duration, onPoll func()) {
+	w.pollInterval = interval
+	w.onPoll = onPoll
+}
+
+// Run calls onChange for every settled file until Close, one call at a time
+func (w *Watcher) Run(onChange func(fullAddress string)) error {
+	var ticks <-chan time.Time
+
+	if w.onPoll != nil {
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+
+			fullAddress := filepath.Clean(event.Name)
+
+			if event.Op&fsnotify.Create != 0 {
+				err := w.created(fullAddress)
+
+				if err != nil {
+					log.Printf("failed to watch new file: %v", err)
+				}
+			}
+
+			// Only the content matters, attribute changes aren't synced
+			if w.files[fullAddress] && event.Op != fsnotify.Chmod {
+				w.touch(fullAddress)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			log.Printf("filesystem watcher error: %v", err)
+		case fullAddress := <-w.changed:
+			onChange(fullAddress)
+		case <-ticks:
+			w.onPoll()
+		case <-w.done:
+			return nil
+		}
+	}
+}
+
+func (w *Watcher) Close() error {
+	close(w.done)
+
+	return w.fsWatcher.Close()
+}
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, address string, content string) {
+	err := ioutil.WriteFile(address, []byte(content), 0640)
+
+	if err != nil {
+		t.Fatalf("failed to write %s: %v", address, err)
+	}
+}
+
+func TestWatcherPicksUpCreatedFiles(t *testing.T) {
+	root := t.TempDir()
+
+	writeFile(t, filepath.Join(root, ".syncignore"), "*.tmp\nbuild/\n")
+
+	w, err := NewWatcher(nil, []string{root}, 100*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+
+	changes := make(chan string, 10)
+
+	go func() {
+		err := w.Run(func(fullAddress string) {
+			changes <- fullAddress
+		})
+
+		if err != nil {
+			t.Errorf("watcher failed: %v", err)
+		}
+	}()
+
+	defer w.Close()
+
+	expected := map[string]bool{
+		filepath.Join(root, "new.txt"):              true,
+		filepath.Join(root, "sub", "nested.txt"):    true,
+		filepath.Join(root, "sub", "deep", "d.txt"): true}
+
+	writeFile(t, filepath.Join(root, "new.txt"), "new")
+	writeFile(t, filepath.Join(root, "editor.tmp"), "ignored")
+
+	for _, dirName := range []string{"sub", "build", filepath.Join("sub", "deep")} {
+		err = os.Mkdir(filepath.Join(root, dirName), 0750)
+
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", dirName, err)
+		}
+	}
+
+	// Give the watch of the new directories a moment to be added
+	time.Sleep(200 * time.Millisecond)
+
+	writeFile(t, filepath.Join(root, "sub", "nested.txt"), "nested")
+	writeFile(t, filepath.Join(root, "sub", "deep", "d.txt"), "deep")
+	writeFile(t, filepath.Join(root, "build", "output.txt"), "ignored")
+	// Patterns of the root apply inside new subdirectories too
+	writeFile(t, filepath.Join(root, "sub", "deep", "editor.tmp"), "ignored")
+
+	timeout := time.After(5 * time.Second)
+
+	for len(expected) > 0 {
+		select {
+		case fullAddress := <-changes:
+			if !expected[fullAddress] {
+				t.Fatalf("unexpected change of %s", fullAddress)
+			}
+
+			delete(expected, fullAddress)
+		case <-timeout:
+			t.Fatalf("changes never reported: %v", expected)
+		}
+	}
+
+	select {
+	case fullAddress := <-changes:
+		t.Errorf("unexpected change of %s", fullAddress)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,78 @@
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// PropertyNamespace holds the dead properties kept on every version resource
+const PropertyNamespace = "https://github.com/ilyail3/fileSync"
+
+const propFindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:F="` + PropertyNamespace + `">
+<D:prop><D:resourcetype/><D:getcontentlength/><F:modified-time/><F:properties/><F:md5-checksum/></D:prop>
+</D:propfind>`
+
+type multiStatus struct {
+	Responses []response `xml:"DAV: response"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	PropStats []propStat `xml:"DAV: propstat"`
+}
+
+type propStat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	ResourceType  resourceType `xml:"DAV: resourcetype"`
+	ContentLength int64        `xml:"DAV: getcontentlength"`
+	ModifiedTime  string       `xml:"https://github.com/ilyail3/fileSync modified-time"`
+	Properties    string       `xml:"https://github.com/ilyail3/fileSync properties"`
+	Md5Checksum   string       `xml:"https://github.com/ilyail3/fileSync md5-checksum"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+}
+
+// found returns the properties of the propstat with a 200 status
+func (r *response) found() (prop, bool) {
+	for _, ps := range r.PropStats {
+		if strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop, true
+		}
+	}
+
+	return prop{}, false
+}
+
+// propPatchBody sets every value as a property of PropertyNamespace, in the given order
+func propPatchBody(names []string, values []string) (string, error) {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:F="` + PropertyNamespace + `">
+<D:set><D:prop>`)
+
+	for i, name := range names {
+		buffer.WriteString("<F:" + name + ">")
+
+		err := xml.EscapeText(&buffer, []byte(values[i]))
+
+		if err != nil {
+			return "", err
+		}
+
+		buffer.WriteString("</F:" + name + ">")
+	}
+
+	buffer.WriteString(`</D:prop></D:set>
+</D:propertyupdate>`)
+
+	return buffer.String(), nil
+}
@@ -0,0 +1,397 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/ilyail3/fileSync/remote"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// WebDAVRemote keeps every version of a file as a resource under <folder>/<name>.versions/<id>,
+// the modified time and properties are stored as dead properties on the version resource
+type WebDAVRemote struct {
+	client   *http.Client
+	baseURL  *url.URL
+	username string
+	password string
+}
+
+func NewWebDAVRemote(client *http.Client, baseURL string, username string, password string) (*WebDAVRemote, error) {
+	parsedURL, err := url.Parse(baseURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webdav url '%s': %v", baseURL, err)
+	}
+
+	return &WebDAVRemote{client: client, baseURL: parsedURL, username: username, password: password}, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+
+	return n, err
+}
+
+func (w *WebDAVRemote) resourceURL(id string, collection bool) string {
+	resource := *w.baseURL
+	resource.Path = path.Join("/", w.baseURL.Path, id)
+
+	if collection {
+		resource.Path += "/"
+	}
+
+	return resource.String()
+}
+
+// idFromHref converts a href returned by PROPFIND back to a path relative to the base url
+func (w *WebDAVRemote) idFromHref(href string) (string, error) {
+	hrefURL, err := url.Parse(href)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to parse href '%s': %v", href, err)
+	}
+
+	basePath := path.Join("/", w.baseURL.Path)
+	id := strings.TrimPrefix(path.Join("/", hrefURL.Path), basePath)
+
+	return strings.Trim(id, "/"), nil
+}
+
+func (w *WebDAVRemote) do(method string, resource string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, resource, body)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %v", method, err)
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	resp, err := w.client.Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %v", method, resource, err)
+	}
+
+	return resp, nil
+}
+
+// expect runs a request without a response body of interest and checks the status is one of the accepted
+func (w *WebDAVRemote) expect(method string, resource string, body io.Reader, headers map[string]string, accepted ...int) (int, error) {
+	resp, err := w.do(method, resource, body, headers)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	for _, status := range accepted {
+		if resp.StatusCode == status {
+			_, err = io.Copy(ioutil.Discard, resp.Body)
+
+			if err != nil {
+				return 0, fmt.Errorf("failed to read %s response: %v", method, err)
+			}
+
+			return resp.StatusCode, nil
+		}
+	}
+
+	return resp.StatusCode, fmt.Errorf("%s %s returned unexpected status: %s", method, resource, resp.Status)
+}
+
+func (w *WebDAVRemote) makeCollection(id string) error {
+	// 405 Method Not Allowed is returned when the collection already exists
+	_, err := w.expect("MKCOL", w.resourceURL(id, true), nil, nil, http.StatusCreated, http.StatusMethodNotAllowed)
+
+	return err
+}
+
+func (w *WebDAVRemote) GetOrCreateDirectory(parentId string, directoryName string) (string, error) {
+	id := path.Join(parentId, directoryName)
+
+	err := w.makeCollection(id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create sync folder: %v", err)
+	}
+
+	return id, nil
+}
+
+func (w *WebDAVRemote) FindDirectory(parentId string, directoryName string) (string, bool, error) {
+	id := path.Join(parentId, directoryName)
+
+	status, err := w.expect(
+		"PROPFIND",
+		w.resourceURL(id, true),
+		strings.NewReader(propFindBody),
+		map[string]string{"Depth": "0", "Content-Type": "application/xml; charset=utf-8"},
+		http.StatusMultiStatus, http.StatusNotFound)
+
+	if err != nil {
+		return "", false, fmt.Errorf("failed to lookup sync folder: %v", err)
+	}
+
+	if status == http.StatusNotFound {
+		return "", false, nil
+	}
+
+	return id, true, nil
+}
+
+func (w *WebDAVRemote) ListVersions(parentId string, fileName string) ([]*remote.Version, error) {
+	versionsDir := remote.VersionsFolder(parentId, fileName)
+	versions := make([]*remote.Version, 0)
+
+	resp, err := w.do(
+		"PROPFIND",
+		w.resourceURL(versionsDir, true),
+		strings.NewReader(propFindBody),
+		map[string]string{"Depth": "1", "Content-Type": "application/xml; charset=utf-8"})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve files: %v", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return versions, nil
+	} else if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("unable to retrieve files, PROPFIND returned: %s", resp.Status)
+	}
+
+	var result multiStatus
+
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PROPFIND response: %v", err)
+	}
+
+	for _, r := range result.Responses {
+		p, ok := r.found()
+
+		// Skips the versions collection itself and versions whose properties were never written
+		if !ok || p.ResourceType.Collection != nil || p.ModifiedTime == "" {
+			continue
+		}
+
+		id, err := w.idFromHref(r.Href)
+
+		if err != nil {
+			return nil, err
+		}
+
+		mTime, err := time.Parse(time.RFC3339, p.ModifiedTime)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse modified time '%s' of %s: %v", p.ModifiedTime, id, err)
+		}
+
+		properties := make(map[string]string)
+
+		if p.Properties != "" {
+			err = json.Unmarshal([]byte(p.Properties), &properties)
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode properties of %s: %v", id, err)
+			}
+		}
+
+		versions = append(versions, &remote.Version{
+			Id:           id,
+			Name:         fileName,
+			ModifiedTime: mTime,
+			Properties:   properties,
+			Md5Checksum:  p.Md5Checksum,
+			Size:         p.ContentLength})
+	}
+
+	return versions, nil
+}
+
+func (w *WebDAVRemote) Upload(parentId string, fileName string, modTime time.Time, properties map[string]string, content io.Reader) (*remote.Version, error) {
+	modTime = remote.VersionTime(modTime)
+
+	err := w.makeCollection(remote.VersionsFolder(parentId, fileName))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create versions folder: %v", err)
+	}
+
+	id, err := remote.NewVersionId(parentId, fileName, modTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	counter := &countingReader{reader: io.TeeReader(content, hash)}
+
+	_, err = w.expect("PUT", w.resourceURL(id, false), counter, nil, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+
+	if err != nil {
+		return nil, fmt.Errorf("upload operation failed: %v", err)
+	}
+
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+
+	propertiesJSON, err := json.Marshal(properties)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode properties: %v", err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	body, err := propPatchBody(
+		[]string{"modified-time", "properties", "md5-checksum"},
+		[]string{modTime.UTC().Format(time.RFC3339), string(propertiesJSON), checksum})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PROPPATCH body: %v", err)
+	}
+
+	// A version is only listed once its properties are set, without them it's removed so it isn't left behind
+	err = w.propPatch(id, body)
+
+	if err != nil {
+		deleteErr := w.Delete(id)
+
+		if deleteErr != nil {
+			log.Printf("failed to remove version %s left without properties: %v", id, deleteErr)
+		}
+
+		return nil, fmt.Errorf("failed to set version properties: %v", err)
+	}
+
+	return &remote.Version{
+		Id:           id,
+		Name:         fileName,
+		ModifiedTime: modTime,
+		Properties:   properties,
+		Md5Checksum:  checksum,
+		Size:         counter.count}, nil
+}
+
+// propPatch sets the properties of a resource, a multistatus response is only a success when every property was set
+func (w *WebDAVRemote) propPatch(id string, body string) error {
+	resp, err := w.do(
+		"PROPPATCH",
+		w.resourceURL(id, false),
+		strings.NewReader(body),
+		map[string]string{"Content-Type": "application/xml; charset=utf-8"})
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	} else if resp.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("PROPPATCH returned unexpected status: %s", resp.Status)
+	}
+
+	var result multiStatus
+
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+
+	if err != nil {
+		return fmt.Errorf("failed to decode PROPPATCH response: %v", err)
+	}
+
+	// 424 Failed Dependency only tells another property of the same update failed, that one holds the cause
+	var dependency = ""
+
+	for _, r := range result.Responses {
+		for _, ps := range r.PropStats {
+			if strings.Contains(ps.Status, " 424 ") {
+				dependency = ps.Status
+			} else if !strings.Contains(ps.Status, " 200 ") {
+				return fmt.Errorf("PROPPATCH of %s returned: %s", r.Href, ps.Status)
+			}
+		}
+	}
+
+	if dependency != "" {
+		return fmt.Errorf("PROPPATCH returned: %s", dependency)
+	}
+
+	return nil
+}
+
+func (w *WebDAVRemote) Download(id string) (io.ReadCloser, error) {
+	resp, err := w.do("GET", w.resourceURL(id, false), nil, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %v", id, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := resp.Body.Close()
+
+		if err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+
+		return nil, fmt.Errorf("failed to download file %s: %s", id, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+func (w *WebDAVRemote) Delete(id string) error {
+	_, err := w.expect("DELETE", w.resourceURL(id, false), nil, nil, http.StatusNoContent, http.StatusOK)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete file %s: %v", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	davserver "golang.org/x/net/webdav"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRemote(t *testing.T) *WebDAVRemote {
+	rmt, _ := newTestServer(t, nil)
+
+	return rmt
+}
+
+// newTestServer serves an in-memory file system, wrap lets a test change how the server answers
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*WebDAVRemote, davserver.FileSystem) {
+	fs := davserver.NewMemFS()
+
+	var handler http.Handler = &davserver.Handler{
+		Prefix:     "/dav",
+		FileSystem: fs,
+		LockSystem: davserver.NewMemLS()}
+
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	rmt, err := NewWebDAVRemote(http.DefaultClient, server.URL+"/dav/", "", "")
+
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+
+	return rmt, fs
+}
+
+func TestGetOrCreateExistingDirectory(t *testing.T) {
+	rmt := newTestRemote(t)
+
+	first, err := rmt.GetOrCreateDirectory("", "folder")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	second, err := rmt.GetOrCreateDirectory("", "folder")
+
+	if err != nil {
+		t.Fatalf("existing folder wasn't accepted: %v", err)
+	}
+
+	if first != second {
+		t.Errorf("expected the same id for the same folder, got %s and %s", first, second)
+	}
+}
+
+func TestUploadRoundTripsProperties(t *testing.T) {
+	rmt := newTestRemote(t)
+	content := []byte("some file content")
+	modTime := time.Date(2020, 5, 17, 10, 30, 15, 500, time.UTC)
+
+	properties := map[string]string{
+		"mode": "0644",
+		"gpg":  "folder/file.txt.sig.versions/20200517T103015Z-00000000",
+		"host": "laptop"}
+
+	folderId, err := rmt.GetOrCreateDirectory("", "folder")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	uploaded, err := rmt.Upload(folderId, "file.txt", modTime, properties, bytes.NewReader(content))
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	versions, err := rmt.ListVersions(folderId, "file.txt")
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(versions))
+	}
+
+	version := versions[0]
+	sum := md5.Sum(content)
+
+	if version.Id != uploaded.Id {
+		t.Errorf("expected id %s, got %s", uploaded.Id, version.Id)
+	}
+
+	if !version.ModifiedTime.Equal(modTime.Truncate(time.Second)) {
+		t.Errorf("expected modified time %v, got %v", modTime.Truncate(time.Second), version.ModifiedTime)
+	}
+
+	if version.Md5Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected checksum %s, got %s", hex.EncodeToString(sum[:]), version.Md5Checksum)
+	}
+
+	if version.Size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), version.Size)
+	}
+
+	for key, value := range properties {
+		if version.Properties[key] != value {
+			t.Errorf("expected property %s=%s, got '%s'", key, value, version.Properties[key])
+		}
+	}
+}
+
+func TestListMissingVersions(t *testing.T) {
+	rmt := newTestRemote(t)
+
+	versions, err := rmt.ListVersions("folder", "missing.txt")
+
+	if err != nil {
+		t.Fatalf("listing a missing file failed: %v", err)
+	}
+
+	if len(versions) != 0 {
+		t.Errorf("expected no versions, got %d", len(versions))
+	}
+}
+
+func TestUploadRemovesVersionWhenPropertiesFail(t *testing.T) {
+	// The server accepts the content, but refuses one of the properties inside a 207 multistatus
+	rmt, fs := newTestServer(t, func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PROPPATCH" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+
+			_, err := w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:" xmlns:F="` + PropertyNamespace + `">
+<D:response><D:href>` + r.URL.Path + `</D:href>
+<D:propstat><D:prop><F:modified-time/><F:md5-checksum/></D:prop><D:status>HTTP/1.1 424 Failed Dependency</D:status></D:propstat>
+<D:propstat><D:prop><F:properties/></D:prop><D:status>HTTP/1.1 507 Insufficient Storage</D:status></D:propstat>
+</D:response>
+</D:multistatus>`))
+
+			if err != nil {
+				t.Errorf("failed to write response: %v", err)
+			}
+		})
+	})
+
+	folderId, err := rmt.GetOrCreateDirectory("", "folder")
+
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+
+	_, err = rmt.Upload(folderId, "file.txt", time.Now(), nil, bytes.NewReader([]byte("content")))
+
+	if err == nil || !strings.Contains(err.Error(), "507") {
+		t.Fatalf("expected the refused property to fail the upload, got %v", err)
+	}
+
+	dir, err := fs.OpenFile(context.Background(), "/folder/file.txt.versions", os.O_RDONLY, 0)
+
+	if err != nil {
+		t.Fatalf("failed to open versions folder: %v", err)
+	}
+
+	entries, err := dir.Readdir(-1)
+
+	if err != nil {
+		t.Fatalf("failed to list versions folder: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected the version without properties to be removed, found %s", entries[0].Name())
+	}
+}